@@ -0,0 +1,110 @@
+package xray_pool
+
+import (
+	"context"
+	"time"
+)
+
+// 熔断状态
+const (
+	breakerClosed   = iota // 正常
+	breakerOpen            // 熔断中,节点隔离
+	breakerHalfOpen        // 冷却结束,允许一次探测
+)
+
+// WithBreaker 设置熔断,节点连续失败failLimit次后隔离,cooldown后放回一次探测请求
+// 探测成功恢复正常,失败继续隔离,failLimit小于等于0不熔断
+func WithBreaker(failLimit int, cooldown time.Duration) Option {
+	return func(p *Pool) {
+		p.failLimit = failLimit
+		if cooldown > 0 {
+			p.cooldown = cooldown
+		}
+	}
+}
+
+// DoReport 获取节点并执行f,根据f返回的错误记录节点的成功或失败,执行完成后归还节点
+// 节点熔断时不会放回代理池
+func (p *Pool) DoReport(ctx context.Context, f func(ctx context.Context, n *Node) error) error {
+	n, err := p.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.Put(n)
+	err = f(ctx, n)
+	if err != nil {
+		n.Fail("")
+	} else {
+		n.Success()
+	}
+	return err
+}
+
+// quarantine 隔离熔断的节点,冷却结束后放回代理池
+func (p *Pool) quarantine(n *Node) {
+	n.breakerMu.Lock()
+	defer n.breakerMu.Unlock()
+	if n.quarantined {
+		return
+	}
+	n.quarantined = true
+	done := p.closed()
+	time.AfterFunc(n.cooldown, func() {
+		n.breakerMu.Lock()
+		n.quarantined = false
+		n.breaker = breakerHalfOpen
+		n.breakerMu.Unlock()
+		select {
+		case <-done:
+			return
+		default:
+		}
+		p.put(n)
+	})
+}
+
+// Fail 记录一次失败,key为请求地址等,可以为空
+// 连续失败次数达到限制,或者探测请求失败时熔断
+func (n *Node) Fail(key string) {
+	n.breakerMu.Lock()
+	defer n.breakerMu.Unlock()
+	if key != "" {
+		n.fail[key]++
+	}
+	n.fails++
+	switch {
+	case n.breaker == breakerHalfOpen:
+		n.breaker = breakerOpen
+	case n.failLimit > 0 && n.fails >= n.failLimit:
+		n.breaker = breakerOpen
+	}
+}
+
+// Success 记录一次成功,重置连续失败次数,恢复熔断
+func (n *Node) Success() {
+	n.breakerMu.Lock()
+	defer n.breakerMu.Unlock()
+	n.fails = 0
+	n.breaker = breakerClosed
+}
+
+// Fails 连续失败次数
+func (n *Node) Fails() int {
+	n.breakerMu.Lock()
+	defer n.breakerMu.Unlock()
+	return n.fails
+}
+
+// HalfOpen 节点是否冷却结束,等待探测
+func (n *Node) HalfOpen() bool {
+	n.breakerMu.Lock()
+	defer n.breakerMu.Unlock()
+	return n.breaker == breakerHalfOpen
+}
+
+// Broken 节点是否熔断中
+func (n *Node) Broken() bool {
+	n.breakerMu.Lock()
+	defer n.breakerMu.Unlock()
+	return n.breaker == breakerOpen
+}
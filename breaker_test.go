@@ -0,0 +1,53 @@
+package xray_pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	p, nodes := testPool(t, 1, WithShared(2))
+	n := nodes[0]
+	n.failLimit, n.cooldown = 2, 50*time.Millisecond
+
+	//连续失败达到限制后熔断,归还时隔离
+	fail := errors.New("fail")
+	for i := 0; i < 2; i++ {
+		if err := p.DoReport(context.Background(), func(ctx context.Context, n *Node) error { return fail }); err != fail {
+			t.Fatalf("want fail, got: %v", err)
+		}
+	}
+	if !n.Broken() || n.Fails() != 2 || p.Len() != 0 {
+		t.Fatalf("broken: %v, fails: %d, len: %d", n.Broken(), n.Fails(), p.Len())
+	}
+
+	//冷却结束后放回,半开状态只允许一次探测
+	time.Sleep(100 * time.Millisecond)
+	if !n.HalfOpen() || p.Len() != 1 {
+		t.Fatalf("half open: %v, len: %d", n.HalfOpen(), p.Len())
+	}
+	if _, err := p.TryGet(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.TryGet(); err != ErrPoolEmpty {
+		t.Fatalf("want ErrPoolEmpty during probe, got: %v", err)
+	}
+
+	//探测失败继续隔离
+	n.Fail("example.com:443")
+	p.Put(n)
+	if !n.Broken() || p.Len() != 0 {
+		t.Fatalf("probe failed, broken: %v, len: %d", n.Broken(), p.Len())
+	}
+
+	//探测成功恢复
+	time.Sleep(100 * time.Millisecond)
+	if err := p.DoReport(context.Background(), func(ctx context.Context, n *Node) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if n.Broken() || n.HalfOpen() || n.Fails() != 0 || p.Len() != 1 {
+		t.Fatalf("not recovered, broken: %v, fails: %d, len: %d", n.Broken(), n.Fails(), p.Len())
+	}
+}
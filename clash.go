@@ -0,0 +1,211 @@
+package xray_pool
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/injoyai/conv"
+	"github.com/injoyai/logs"
+	"gopkg.in/yaml.v3"
+)
+
+var clashRegexp = regexp.MustCompile(`(?m)^proxies:`)
+
+// isClash 是否是Clash/Clash.Meta的yaml配置
+func isClash(bs []byte) bool {
+	return clashRegexp.Match(bs)
+}
+
+// decodeClash 解析Clash/Clash.Meta配置中的proxies,转换成节点链接
+// 支持vmess,vless,trojan,ss,hysteria2,tuic,不支持的类型会被跳过
+func decodeClash(bs []byte) ([]string, error) {
+	var cfg struct {
+		Proxies []clashProxy `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal(bs, &cfg); err != nil {
+		return nil, err
+	}
+	ls := make([]string, 0, len(cfg.Proxies))
+	for _, p := range cfg.Proxies {
+		l, err := p.link()
+		if err != nil {
+			logs.Warn(err)
+			continue
+		}
+		ls = append(ls, l)
+	}
+	return ls, nil
+}
+
+type clashProxy struct {
+	Name              string   `yaml:"name"`
+	Type              string   `yaml:"type"`
+	Server            string   `yaml:"server"`
+	Port              int      `yaml:"port"`
+	UUID              string   `yaml:"uuid"`
+	AlterID           int      `yaml:"alterId"`
+	Cipher            string   `yaml:"cipher"`
+	Password          string   `yaml:"password"`
+	Flow              string   `yaml:"flow"`
+	TLS               bool     `yaml:"tls"`
+	SkipCertVerify    bool     `yaml:"skip-cert-verify"`
+	ServerName        string   `yaml:"servername"`
+	SNI               string   `yaml:"sni"`
+	Alpn              []string `yaml:"alpn"`
+	ClientFingerprint string   `yaml:"client-fingerprint"`
+	Network           string   `yaml:"network"`
+	Plugin            string   `yaml:"plugin"`
+	Obfs              string   `yaml:"obfs"`
+	ObfsPassword      string   `yaml:"obfs-password"`
+	Congestion        string   `yaml:"congestion-controller"`
+	UdpRelayMode      string   `yaml:"udp-relay-mode"`
+	PluginOpts        struct {
+		Mode string `yaml:"mode"`
+		Host string `yaml:"host"`
+		Path string `yaml:"path"`
+		TLS  bool   `yaml:"tls"`
+	} `yaml:"plugin-opts"`
+	WsOpts struct {
+		Path    string            `yaml:"path"`
+		Headers map[string]string `yaml:"headers"`
+	} `yaml:"ws-opts"`
+	GrpcOpts struct {
+		ServiceName string `yaml:"grpc-service-name"`
+	} `yaml:"grpc-opts"`
+	H2Opts struct {
+		Host []string `yaml:"host"`
+		Path string   `yaml:"path"`
+	} `yaml:"h2-opts"`
+	HttpOpts struct {
+		Path    []string            `yaml:"path"`
+		Headers map[string][]string `yaml:"headers"`
+	} `yaml:"http-opts"`
+	RealityOpts struct {
+		PublicKey string `yaml:"public-key"`
+		ShortID   string `yaml:"short-id"`
+	} `yaml:"reality-opts"`
+}
+
+// link 转换成节点链接
+func (p clashProxy) link() (string, error) {
+	switch p.Type {
+	case Vmess:
+		return p.vmessLink()
+	case Vless:
+		return p.queryLink(Vless, p.UUID), nil
+	case Trojan:
+		return p.queryLink(Trojan, p.Password), nil
+	case "ss":
+		return p.ssLink()
+	case Hysteria2:
+		return (&Hysteria2Config{
+			remark:       p.Name,
+			hostname:     p.Server,
+			port:         p.Port,
+			password:     p.Password,
+			obfs:         p.Obfs,
+			obfsPassword: p.ObfsPassword,
+			sni:          p.SNI,
+			insecure:     p.SkipCertVerify,
+			alpn:         p.Alpn,
+		}).Link()
+	case Tuic:
+		return (&TuicConfig{
+			remark:            p.Name,
+			hostname:          p.Server,
+			port:              p.Port,
+			uuid:              p.UUID,
+			password:          p.Password,
+			congestionControl: p.Congestion,
+			udpRelayMode:      p.UdpRelayMode,
+			sni:               p.SNI,
+			insecure:          p.SkipCertVerify,
+			alpn:              p.Alpn,
+		}).Link()
+	default:
+		return "", fmt.Errorf("unsupported clash proxy type: %s(%s)", p.Type, p.Name)
+	}
+}
+
+// transport 传输参数
+func (p clashProxy) transport() transport {
+	t := transport{
+		Network:       conv.Select(p.Network == "", "tcp", p.Network),
+		SNI:           conv.Select(p.SNI == "", p.ServerName, p.SNI),
+		Alpn:          strings.Join(p.Alpn, ","),
+		Fingerprint:   p.ClientFingerprint,
+		AllowInsecure: p.SkipCertVerify,
+		PublicKey:     p.RealityOpts.PublicKey,
+		ShortID:       p.RealityOpts.ShortID,
+	}
+	switch {
+	case p.RealityOpts.PublicKey != "":
+		t.Security = "reality"
+	case p.TLS || p.Type == Trojan:
+		t.Security = "tls"
+	default:
+		t.Security = None
+	}
+	switch t.Network {
+	case "ws":
+		t.Path = p.WsOpts.Path
+		t.Host = header(p.WsOpts.Headers, "Host")
+	case "grpc":
+		t.Path = p.GrpcOpts.ServiceName
+	case "h2":
+		t.Path = p.H2Opts.Path
+		t.Host = strings.Join(p.H2Opts.Host, ",")
+	case "http":
+		//clash的http是tcp的http伪装
+		t.Network = "tcp"
+		t.HeaderType = "http"
+		t.Path = strings.Join(p.HttpOpts.Path, ",")
+		for k, v := range p.HttpOpts.Headers {
+			if strings.EqualFold(k, "Host") {
+				t.Host = strings.Join(v, ",")
+			}
+		}
+	}
+	return t
+}
+
+func (p clashProxy) vmessLink() (string, error) {
+	return vmessLink(p.Name, p.Server, p.Port, p.UUID, p.AlterID, p.Cipher, p.transport())
+}
+
+func (p clashProxy) queryLink(protocol, user string) string {
+	return queryLink(protocol, p.Name, p.Server, p.Port, user, map[string]string{"flow": p.Flow}, p.transport())
+}
+
+func (p clashProxy) ssLink() (string, error) {
+	plugin := ""
+	switch p.Plugin {
+	case "":
+	case "v2ray-plugin", "xray-plugin":
+		opts := []string{p.Plugin, "mode=" + conv.Select(p.PluginOpts.Mode == "", "websocket", p.PluginOpts.Mode)}
+		if p.PluginOpts.Host != "" {
+			opts = append(opts, "host="+p.PluginOpts.Host)
+		}
+		if p.PluginOpts.Path != "" {
+			opts = append(opts, "path="+p.PluginOpts.Path)
+		}
+		if p.PluginOpts.TLS {
+			opts = append(opts, "tls")
+		}
+		plugin = strings.Join(opts, ";")
+	default:
+		return "", fmt.Errorf("unsupported shadowsocks plugin: %s(%s)", p.Plugin, p.Name)
+	}
+	return ssLink(p.Name, p.Server, p.Port, p.Cipher, p.Password, plugin), nil
+}
+
+// header 不区分大小写获取请求头
+func header(m map[string]string, key string) string {
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
@@ -5,13 +5,17 @@ import (
 )
 
 const (
-	Vmess  = "vmess"
-	Vless  = "vless"
-	Trojan = "trojan"
-	Socks  = "socks"
-	Http   = "http"
-	Mixed  = "mixed"
-	None   = "none"
+	Vmess       = "vmess"
+	Vless       = "vless"
+	Trojan      = "trojan"
+	Shadowsocks = "shadowsocks"
+	Wireguard   = "wireguard"
+	Socks       = "socks"
+	Http        = "http"
+	Hysteria2   = "hysteria2"
+	Tuic        = "tuic"
+	Mixed       = "mixed"
+	None        = "none"
 )
 
 var (
@@ -52,9 +56,10 @@ var (
 )
 
 type Config struct {
-	Log       Log     `json:"log"`
-	Inbounds  []Bound `json:"inbounds"`
-	Outbounds []Bound `json:"outbounds"`
+	Log       Log      `json:"log"`
+	Inbounds  []Bound  `json:"inbounds"`
+	Outbounds []Bound  `json:"outbounds"`
+	Routing   *Routing `json:"routing,omitempty"`
 }
 
 func (c *Config) String() string {
@@ -73,6 +78,7 @@ type Log struct {
 }
 
 type Bound struct {
+	Tag            string          `json:"tag,omitempty"`
 	Listen         string          `json:"listen,omitempty"`
 	Port           int             `json:"port,omitempty"`
 	Protocol       string          `json:"protocol"`
@@ -80,16 +86,133 @@ type Bound struct {
 	StreamSettings *StreamSettings `json:"streamSettings,omitempty"`
 }
 
+type Routing struct {
+	DomainStrategy string `json:"domainStrategy,omitempty"`
+	Rules          []Rule `json:"rules"`
+}
+
+type Rule struct {
+	Type        string   `json:"type"`
+	InboundTag  []string `json:"inboundTag,omitempty"`
+	OutboundTag string   `json:"outboundTag"`
+}
+
 type Settings struct {
+	raw json.RawMessage // 从配置文件读取时的原始内容,序列化时原样输出
+
 	Udp     bool     `json:"udp,omitempty"`
 	Vnext   []Vnext  `json:"vnext,omitempty"`
 	Servers []Server `json:"servers,omitempty"`
+
+	// wireguard
+	SecretKey string   `json:"secretKey,omitempty"`
+	Address   []string `json:"address,omitempty"`
+	Peers     []Peer   `json:"peers,omitempty"`
+	MTU       int      `json:"mtu,omitempty"`
+	Reserved  []int    `json:"reserved,omitempty"`
+}
+
+type Peer struct {
+	Endpoint     string   `json:"endpoint"`
+	PublicKey    string   `json:"publicKey"`
+	PreSharedKey string   `json:"preSharedKey,omitempty"`
+	KeepAlive    int      `json:"keepAlive,omitempty"`
+	AllowedIPs   []string `json:"allowedIPs,omitempty"`
 }
 
 type StreamSettings struct {
-	Network         string          `json:"network"`
-	Security        string          `json:"security"`
-	RealitySettings RealitySettings `json:"realitySettings"`
+	raw json.RawMessage // 从配置文件读取时的原始内容,序列化时原样输出
+
+	Network             string               `json:"network"`
+	Security            string               `json:"security"`
+	RealitySettings     *RealitySettings     `json:"realitySettings,omitempty"`
+	TlsSettings         *TlsSettings         `json:"tlsSettings,omitempty"`
+	TcpSettings         *TcpSettings         `json:"tcpSettings,omitempty"`
+	WsSettings          *WsSettings          `json:"wsSettings,omitempty"`
+	GrpcSettings        *GrpcSettings        `json:"grpcSettings,omitempty"`
+	HttpSettings        *HttpSettings        `json:"httpSettings,omitempty"`
+	HttpupgradeSettings *HttpupgradeSettings `json:"httpupgradeSettings,omitempty"`
+	XhttpSettings       *XhttpSettings       `json:"xhttpSettings,omitempty"`
+}
+
+func (s *Settings) UnmarshalJSON(bs []byte) error {
+	type settings Settings
+	//字段类型不一致时忽略,原始内容会保留
+	_ = json.Unmarshal(bs, (*settings)(s))
+	s.raw = append(json.RawMessage(nil), bs...)
+	return nil
+}
+
+func (s Settings) MarshalJSON() ([]byte, error) {
+	if s.raw != nil {
+		return s.raw, nil
+	}
+	type settings Settings
+	return json.Marshal(settings(s))
+}
+
+func (s *StreamSettings) UnmarshalJSON(bs []byte) error {
+	type streamSettings StreamSettings
+	//字段类型不一致时忽略,原始内容会保留
+	_ = json.Unmarshal(bs, (*streamSettings)(s))
+	s.raw = append(json.RawMessage(nil), bs...)
+	return nil
+}
+
+func (s StreamSettings) MarshalJSON() ([]byte, error) {
+	if s.raw != nil {
+		return s.raw, nil
+	}
+	type streamSettings StreamSettings
+	return json.Marshal(streamSettings(s))
+}
+
+type TlsSettings struct {
+	ServerName    string   `json:"serverName,omitempty"`
+	AllowInsecure bool     `json:"allowInsecure,omitempty"`
+	Alpn          []string `json:"alpn,omitempty"`
+	Fingerprint   string   `json:"fingerprint,omitempty"`
+}
+
+type TcpSettings struct {
+	Header *Header `json:"header,omitempty"`
+}
+
+type Header struct {
+	Type    string         `json:"type"`
+	Request *HeaderRequest `json:"request,omitempty"`
+}
+
+type HeaderRequest struct {
+	Path    []string            `json:"path,omitempty"`
+	Headers map[string][]string `json:"headers,omitempty"`
+}
+
+type GrpcSettings struct {
+	ServiceName string `json:"serviceName"`
+	MultiMode   bool   `json:"multiMode,omitempty"`
+}
+
+type HttpSettings struct {
+	Host []string `json:"host,omitempty"`
+	Path string   `json:"path,omitempty"`
+}
+
+type HttpupgradeSettings struct {
+	Path string `json:"path,omitempty"`
+	Host string `json:"host,omitempty"`
+}
+
+type XhttpSettings struct {
+	Path string `json:"path,omitempty"`
+	Host string `json:"host,omitempty"`
+	Mode string `json:"mode,omitempty"`
+}
+
+type WsSettings struct {
+	Path    string            `json:"path,omitempty"`
+	Host    string            `json:"host,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 type RealitySettings struct {
@@ -98,14 +221,21 @@ type RealitySettings struct {
 	Show          bool   `json:"show"`
 	PublicKey     string `json:"publicKey"`
 	ShortID       string `json:"shortId"`
-	SpiderX       string `json:"spideX"`
-	Mldsa64Verify string `json:"mldsa64Verify"`
+	SpiderX       string `json:"spiderX"`
+	Mldsa64Verify string `json:"mldsa64Verify,omitempty"`
 }
 
 type Server struct {
-	Address  string `json:"address"`
-	Port     int    `json:"port"`
-	Password string `json:"password"`
+	Address  string       `json:"address"`
+	Port     int          `json:"port"`
+	Method   string       `json:"method,omitempty"`
+	Password string       `json:"password,omitempty"`
+	Users    []ServerUser `json:"users,omitempty"`
+}
+
+type ServerUser struct {
+	User string `json:"user"`
+	Pass string `json:"pass"`
 }
 
 type Vnext struct {
@@ -119,6 +249,7 @@ type User struct {
 	AlterId    int    `json:"alterId"`
 	Encryption string `json:"encryption"`
 	Flow       string `json:"flow"`
+	Security   string `json:"security,omitempty"`
 }
 
 /*
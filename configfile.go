@@ -0,0 +1,220 @@
+package xray_pool
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/injoyai/conv"
+)
+
+// WithConfigFile 从xray/v2ray的json配置文件中读取节点
+// 除freedom,blackhole,dns,loopback外的每个出站都是一个节点,settings和streamSettings原样使用,支持注释
+func WithConfigFile(paths ...string) Option {
+	return func(p *Pool) {
+		p.configFiles = append(p.configFiles, paths...)
+	}
+}
+
+// configFile 解析后的配置文件
+type configFile struct {
+	modTime time.Time
+	size    int64
+	links   []string         // 可以作为节点的出站链接,按出现顺序
+	bounds  map[string]Bound // 出站内容的哈希 -> 出站
+}
+
+// configCache 配置文件路径对应的解析结果,只缓存WithConfigFile设置的文件
+type configCache map[string]*configFile
+
+// readConfig 读取xray/v2ray的json配置文件,支持注释
+func readConfig(path string) (*configFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := new(Config)
+	if err = json.Unmarshal(stripComments(bs), cfg); err != nil {
+		return nil, fmt.Errorf("read config %s: %w", path, err)
+	}
+	f := &configFile{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		bounds:  make(map[string]Bound),
+	}
+	for _, b := range cfg.Outbounds {
+		switch b.Protocol {
+		case "freedom", "blackhole", "dns", "loopback":
+			continue
+		}
+		bs, err := json.Marshal(b)
+		if err != nil {
+			return nil, fmt.Errorf("read config %s: %w", path, err)
+		}
+		sum := sha1.Sum(bs)
+		hash := hex.EncodeToString(sum[:])
+		if _, ok := f.bounds[hash]; !ok {
+			f.links = append(f.links, fmt.Sprintf("file://%s#%s", path, hash))
+		}
+		f.bounds[hash] = b
+	}
+	return f, nil
+}
+
+// loadConfig 读取WithConfigFile设置的配置文件,每个文件只在修改后重新解析
+// 其他路径返回错误,订阅内容中的file://链接不能读取本地文件
+func (p *Pool) loadConfig(path string) (*configFile, error) {
+	if !slices.Contains(p.configFiles, path) {
+		return nil, fmt.Errorf("config file not configured: %s", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p.configMu.Lock()
+	defer p.configMu.Unlock()
+	if f, ok := p.configs[path]; ok && f.modTime.Equal(info.ModTime()) && f.size == info.Size() {
+		return f, nil
+	}
+	f, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if p.configs == nil {
+		p.configs = make(configCache)
+	}
+	p.configs[path] = f
+	return f, nil
+}
+
+// configFileLinks 配置文件中可以作为节点的出站,格式 file://<路径>#<出站内容的哈希>
+// 出站内容不变时链接不变,调整出站的顺序不会影响节点
+func (p *Pool) configFileLinks(path string) ([]string, error) {
+	f, err := p.loadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.links, nil
+}
+
+// parseConfigFile 解析configFileLinks生成的链接
+func (p *Pool) parseConfigFile(raw string) (*BoundConfig, error) {
+	path, hash, ok := strings.Cut(strings.TrimPrefix(raw, "file://"), "#")
+	if !ok {
+		return nil, fmt.Errorf("invalid config file link, missing outbound hash: %s", raw)
+	}
+	f, err := p.loadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.bound(raw, hash)
+}
+
+// ParseConfigFile 解析 file://<路径>#<出站内容的哈希> 链接,读取配置文件中对应的出站
+func ParseConfigFile(raw string) (*BoundConfig, error) {
+	path, hash, ok := strings.Cut(strings.TrimPrefix(raw, "file://"), "#")
+	if !ok {
+		return nil, fmt.Errorf("invalid config file link, missing outbound hash: %s", raw)
+	}
+	f, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.bound(raw, hash)
+}
+
+func (f *configFile) bound(raw, hash string) (*BoundConfig, error) {
+	b, ok := f.bounds[hash]
+	if !ok {
+		return nil, fmt.Errorf("outbound not found in config file: %s", raw)
+	}
+	return &BoundConfig{bound: b}, nil
+}
+
+// stripComments 去掉json中的注释(//,/* */和#),和xray读取配置文件的行为一致,字符串中的内容不处理
+func stripComments(bs []byte) []byte {
+	out := make([]byte, 0, len(bs))
+	for i := 0; i < len(bs); i++ {
+		switch c := bs[i]; {
+		case c == '"':
+			//字符串,原样保留,跳过转义的字符
+			j := i + 1
+			for ; j < len(bs) && bs[j] != '"'; j++ {
+				if bs[j] == '\\' {
+					j++
+				}
+			}
+			j = min(j, len(bs)-1)
+			out = append(out, bs[i:j+1]...)
+			i = j
+		case c == '#', c == '/' && i+1 < len(bs) && bs[i+1] == '/':
+			for i < len(bs) && bs[i] != '\n' {
+				i++
+			}
+			if i < len(bs) {
+				out = append(out, '\n')
+			}
+		case c == '/' && i+1 < len(bs) && bs[i+1] == '*':
+			end := bytes.Index(bs[i+2:], []byte("*/"))
+			if end < 0 {
+				return out
+			}
+			//保留换行,json解析出错时行号不变
+			out = append(out, bytes.Repeat([]byte("\n"), bytes.Count(bs[i:i+2+end], []byte("\n")))...)
+			i += end + 3
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// BoundConfig 配置文件中的出站,settings和streamSettings原样使用
+type BoundConfig struct {
+	bound Bound
+}
+
+func (c *BoundConfig) Remark() string   { return c.bound.Tag }
+func (c *BoundConfig) Protocol() string { return c.bound.Protocol }
+
+func (c *BoundConfig) Hostname() string {
+	host, _ := c.address()
+	return host
+}
+
+func (c *BoundConfig) Port() int {
+	_, port := c.address()
+	return port
+}
+
+func (c *BoundConfig) Settings() *Settings { return c.bound.Settings }
+
+func (c *BoundConfig) StreamSettings() *StreamSettings { return c.bound.StreamSettings }
+
+// address 服务器地址和端口
+func (c *BoundConfig) address() (string, int) {
+	s := c.bound.Settings
+	switch {
+	case s == nil:
+	case len(s.Vnext) > 0:
+		return s.Vnext[0].Address, s.Vnext[0].Port
+	case len(s.Servers) > 0:
+		return s.Servers[0].Address, s.Servers[0].Port
+	case len(s.Peers) > 0:
+		host, port, _ := net.SplitHostPort(s.Peers[0].Endpoint)
+		return host, conv.Int(port)
+	}
+	return "", 0
+}
@@ -0,0 +1,74 @@
+package xray_pool
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestConfigFile(t *testing.T) {
+	vmess := `{"tag": "vmess", "protocol": "vmess", // 注释
+      "settings": {"vnext": [{"address": "v.example.com", "port": 443, "users": [{"id": "b831381d-6324-4d53-ad4f-8cda48b30811"}]}]},
+      "streamSettings": {"network": "ws", "wsSettings": {"path": "/ws#a//b"}}}`
+	trojan := `{"tag": "trojan", "protocol": "trojan", # 注释
+      "settings": {"servers": [{"address": "t.example.com", "port": 443, "password": "p/*w*/d"}]}}`
+	direct := `{"tag": "direct", "protocol": "freedom"}`
+	write := func(path string, outbounds ...string) {
+		bs := "/* 配置\n文件 */\n{\"outbounds\": [\n"
+		for i, o := range outbounds {
+			if i > 0 {
+				bs += ",\n"
+			}
+			bs += o
+		}
+		bs += "\n]}\n"
+		if err := os.WriteFile(path, []byte(bs), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	write(path, vmess, direct, trojan)
+	p := New(WithConfigFile(path))
+	links, err := p.configFileLinks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 2 {
+		t.Fatalf("want 2 links, got: %v", links)
+	}
+	for i, want := range []string{"vmess v.example.com:443 vmess ws/ /ws#a//b ", "trojan t.example.com:443 trojan"} {
+		n, err := p.parseNode(links[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := describe(n); got != want {
+			t.Fatalf("link %d:\nwant: %s\ngot:  %s", i, want, got)
+		}
+	}
+	c, err := ParseConfigFile(links[1])
+	if err != nil || c.Settings().Servers[0].Password != "p/*w*/d" {
+		t.Fatalf("trojan password mismatch: %v", err)
+	}
+
+	//调整出站顺序不改变链接
+	write(path, trojan, vmess, `{"tag": "new", "protocol": "socks", "settings": {"servers": [{"address": "s.example.com", "port": 1080}]}}`)
+	changed, err := p.configFileLinks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 3 || !slices.Contains(changed, links[0]) || !slices.Contains(changed, links[1]) {
+		t.Fatalf("links changed:\n%v\n%v", links, changed)
+	}
+
+	//订阅内容中的file://链接不读取本地文件
+	other := filepath.Join(t.TempDir(), "other.json")
+	write(other, vmess)
+	if _, err := p.parseNode("file://" + other + "#" + links[0][len("file://"+path+"#"):]); err == nil {
+		t.Fatal("want error for file not configured")
+	}
+	if _, err := p.parseNode("file://" + other); err == nil {
+		t.Fatal("want error for file not configured")
+	}
+}
@@ -0,0 +1,228 @@
+package xray_pool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	SingBoxCmd = []string{"sing-box", "run", "-c"}
+	MihomoCmd  = []string{"mihomo", "-f"}
+
+	// defaultCore 未指定内核的节点和单进程模式使用的内核
+	defaultCore = Xray()
+)
+
+// Core 代理内核,把节点渲染成内核的配置,并从内核的输出识别启动失败的原因
+// 是否启动成功通过在入站端口上完成握手来确认,和内核的日志格式无关
+type Core interface {
+	// Name 内核名称
+	Name() string
+	// Cmd 默认的启动命令,配置文件路径追加在最后
+	Cmd() []string
+	// Ext 配置文件的扩展名
+	Ext() string
+	// Support 是否支持该协议的节点
+	Support(protocol string) bool
+	// Config 生成节点的配置,节点在listenPort上以listenProtocol监听
+	Config(n *Node) ([]byte, error)
+	// Error 解析内核输出的一行,启动失败时返回错误,例如端口被占用
+	Error(line string) error
+}
+
+// WithCore 设置代理内核,可以设置多个,节点使用第一个支持该协议的内核
+// 默认只有xray,例如 WithCore(Xray(), SingBox()) 时hysteria2和tuic节点会使用sing-box
+// WithCore(Mihomo()) 时所有节点都使用mihomo
+func WithCore(cores ...Core) Option {
+	return func(p *Pool) {
+		if len(cores) > 0 {
+			p.cores = cores
+		}
+	}
+}
+
+// coreOf 选择支持节点协议的内核
+func (p *Pool) coreOf(n *Node) (Core, error) {
+	for _, c := range p.cores {
+		if c.Support(n.Protocol()) {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("no core supports protocol %s: %s", n.Protocol(), n.origin)
+}
+
+// cmdOf 节点的启动命令,WithCmd只对xray和v2ray内核生效,未设置时使用内核的默认命令
+func (p *Pool) cmdOf(n *Node) []string {
+	if _, ok := n.Core().(*xrayCore); ok && len(p.cmd) > 0 {
+		return p.cmd
+	}
+	return n.Core().Cmd()
+}
+
+// hosted 节点是否运行在单进程模式的进程中,单进程模式只支持xray内核
+func (p *Pool) hosted(n *Node) bool {
+	if p.host == nil {
+		return false
+	}
+	_, ok := n.core.(*xrayCore)
+	return ok || n.core == nil
+}
+
+// Xray xray内核
+func Xray() Core {
+	return &xrayCore{name: "xray", cmd: XrayCmd}
+}
+
+// V2ray v2ray内核,配置格式和xray相同
+func V2ray() Core {
+	return &xrayCore{name: "v2ray", cmd: V2rayCmd}
+}
+
+type xrayCore struct {
+	name string
+	cmd  []string
+}
+
+func (c *xrayCore) Name() string  { return c.name }
+func (c *xrayCore) Cmd() []string { return c.cmd }
+func (c *xrayCore) Ext() string   { return ".json" }
+
+func (c *xrayCore) Support(protocol string) bool {
+	switch protocol {
+	case Hysteria2, Tuic:
+		return false
+	}
+	return true
+}
+
+func (c *xrayCore) Config(n *Node) ([]byte, error) {
+	if !c.Support(n.Protocol()) {
+		return nil, fmt.Errorf("%s unsupported protocol: %s", c.name, n.Protocol())
+	}
+	config := Config{
+		Log:       DefaultLog,
+		Inbounds:  []Bound{n.inbound()},
+		Outbounds: []Bound{n.outbound()},
+	}
+	return config.Bytes(), nil
+}
+
+func (c *xrayCore) Error(line string) error {
+	switch {
+	case strings.Contains(line, "address already in use"),
+		strings.Contains(line, "bind: Only one usage of each socket address"):
+		return ErrInvalidPort
+	}
+	return nil
+}
+
+// SingBox sing-box内核,额外支持hysteria2和tuic
+func SingBox() Core {
+	return &singBoxCore{cmd: SingBoxCmd}
+}
+
+type singBoxCore struct {
+	cmd []string
+}
+
+func (c *singBoxCore) Name() string  { return "sing-box" }
+func (c *singBoxCore) Cmd() []string { return c.cmd }
+func (c *singBoxCore) Ext() string   { return ".json" }
+
+func (c *singBoxCore) Support(protocol string) bool {
+	switch protocol {
+	case Vmess, Vless, Trojan, Shadowsocks, Wireguard, Socks, Http, Hysteria2, Tuic:
+		return true
+	}
+	return false
+}
+
+func (c *singBoxCore) Config(n *Node) ([]byte, error) {
+	out, err := singBoxExport(n, "out")
+	if err != nil {
+		return nil, err
+	}
+	config := map[string]any{
+		"log": map[string]any{"level": "info"},
+		"inbounds": []map[string]any{{
+			"type":        n.listenProtocol,
+			"tag":         "in",
+			"listen":      "0.0.0.0",
+			"listen_port": n.ListenPort(),
+		}},
+		"outbounds": []map[string]any{out},
+	}
+	return json.Marshal(config)
+}
+
+func (c *singBoxCore) Error(line string) error {
+	switch {
+	case strings.Contains(line, "address already in use"),
+		strings.Contains(line, "Only one usage of each socket address"):
+		return ErrInvalidPort
+	case strings.Contains(line, "FATAL"):
+		return errors.New(line)
+	}
+	return nil
+}
+
+// Mihomo mihomo(Clash.Meta)内核,使用Clash的yaml配置
+func Mihomo() Core {
+	return &mihomoCore{cmd: MihomoCmd}
+}
+
+type mihomoCore struct {
+	cmd []string
+}
+
+func (c *mihomoCore) Name() string  { return "mihomo" }
+func (c *mihomoCore) Cmd() []string { return c.cmd }
+func (c *mihomoCore) Ext() string   { return ".yaml" }
+
+func (c *mihomoCore) Support(protocol string) bool {
+	switch protocol {
+	case Vmess, Vless, Trojan, Shadowsocks, Wireguard, Socks, Http, Hysteria2, Tuic:
+		return true
+	}
+	return false
+}
+
+func (c *mihomoCore) Config(n *Node) ([]byte, error) {
+	proxy, err := clashExport(n, "out")
+	if err != nil {
+		return nil, err
+	}
+	config := map[string]any{
+		"allow-lan":    true,
+		"bind-address": "*",
+		"mode":         "rule",
+		"log-level":    "info",
+		"proxies":      []map[string]any{proxy},
+		"rules":        []string{"MATCH,out"},
+	}
+	//入站端口,默认同时支持http和socks5的mixed-port
+	switch n.listenProtocol {
+	case Socks:
+		config["socks-port"] = n.ListenPort()
+	case Http:
+		config["port"] = n.ListenPort()
+	default:
+		config["mixed-port"] = n.ListenPort()
+	}
+	return yaml.Marshal(config)
+}
+
+func (c *mihomoCore) Error(line string) error {
+	switch {
+	case strings.Contains(line, "address already in use"),
+		strings.Contains(line, "Only one usage of each socket address"):
+		return ErrInvalidPort
+	case strings.Contains(line, "level=fatal"):
+		return errors.New(line)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package xray_pool
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Dial 通过节点连接目标地址
+func (n *Node) Dial(network, addr string) (net.Conn, error) {
+	return n.DialContext(context.Background(), network, addr)
+}
+
+// DialContext 通过节点连接目标地址,socks和mixed使用socks5握手,http使用CONNECT
+// 节点拒绝连接目标地址时返回的错误包含ErrTarget
+func (n *Node) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if n.Closed() {
+		return nil, fmt.Errorf("node closed: %s", n.origin)
+	}
+	d := net.Dialer{Timeout: DefaultTimeout}
+	conn, err := d.DialContext(ctx, "tcp", fmt.Sprintf("127.0.0.1:%d", n.ListenPort()))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(DefaultTimeout))
+	}
+	switch n.listenProtocol {
+	case Http:
+		err = httpConnect(conn, addr)
+	default:
+		err = socks5Connect(conn, addr)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// httpConnect 通过http代理的CONNECT方法建立隧道
+func httpConnect(conn net.Conn, addr string) error {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+	header, err := readHeader(conn)
+	if err != nil {
+		return err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(header)), req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: http connect %s: %s", ErrTarget, addr, resp.Status)
+	}
+	return nil
+}
+
+// readHeader 逐字节读取http响应头,不多读隧道中的数据
+func readHeader(r io.Reader) ([]byte, error) {
+	header := make([]byte, 0, 128)
+	b := make([]byte, 1)
+	for !bytes.HasSuffix(header, []byte("\r\n\r\n")) {
+		if len(header) >= 8<<10 {
+			return nil, errors.New("http connect response header too large")
+		}
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		header = append(header, b[0])
+	}
+	return header, nil
+}
+
+// socks5Connect 通过socks5代理(无认证)连接目标地址
+func socks5Connect(conn net.Conn, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return err
+	}
+
+	//协商认证方式
+	if _, err = conn.Write([]byte{0x05, 0x01, 0x00}); err != nil {
+		return err
+	}
+	buf := make([]byte, 2)
+	if _, err = io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	if buf[0] != 0x05 || buf[1] != 0x00 {
+		return errors.New("socks5 handshake failed")
+	}
+
+	//请求连接
+	req := []byte{0x05, 0x01, 0x00}
+	req = appendSocks5Addr(req, host)
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err = conn.Write(req); err != nil {
+		return err
+	}
+	buf = make([]byte, 4)
+	if _, err = io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	if buf[1] != 0x00 {
+		return fmt.Errorf("%w: socks5 connect %s failed, code: %d", ErrTarget, addr, buf[1])
+	}
+	_, _, err = readSocks5Addr(conn, buf[3])
+	return err
+}
+
+func appendSocks5Addr(bs []byte, host string) []byte {
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return append(append(bs, 0x01), ip4...)
+		}
+		return append(append(bs, 0x04), ip.To16()...)
+	}
+	return append(append(bs, 0x03, byte(len(host))), host...)
+}
+
+// readSocks5Addr 读取socks5地址和端口,atyp为地址类型
+func readSocks5Addr(r io.Reader, atyp byte) (string, int, error) {
+	var host string
+	switch atyp {
+	case 0x01, 0x04:
+		ip := make([]byte, net.IPv4len)
+		if atyp == 0x04 {
+			ip = make([]byte, net.IPv6len)
+		}
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return "", 0, err
+		}
+		host = net.IP(ip).String()
+	case 0x03:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(r, l); err != nil {
+			return "", 0, err
+		}
+		domain := make([]byte, l[0])
+		if _, err := io.ReadFull(r, domain); err != nil {
+			return "", 0, err
+		}
+		host = string(domain)
+	default:
+		return "", 0, fmt.Errorf("invalid socks5 address type: %d", atyp)
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(r, port); err != nil {
+		return "", 0, err
+	}
+	return host, int(binary.BigEndian.Uint16(port)), nil
+}
+
+// probeInbound 在本地入站端口上完成一次握手,确认内核已经可以提供代理
+// socks和mixed发送socks5的认证协商,http发送一个非代理请求,收到http响应即可
+func probeInbound(protocol string, port int, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	switch protocol {
+	case Http:
+		if _, err = conn.Write([]byte("GET / HTTP/1.1\r\n\r\n")); err != nil {
+			return err
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	default:
+		if _, err = conn.Write([]byte{0x05, 0x01, 0x00}); err != nil {
+			return err
+		}
+		buf := make([]byte, 2)
+		if _, err = io.ReadFull(conn, buf); err != nil {
+			return err
+		}
+		if buf[0] != 0x05 || buf[1] != 0x00 {
+			return fmt.Errorf("unexpected socks5 handshake response: %x", buf)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,171 @@
+package xray_pool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDial(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		addr     string
+		serve    func(c net.Conn, addr string) error
+		target   bool // 是否应该返回ErrTarget
+	}{
+		{"http", Http, "example.com:443", httpProxy(http.StatusOK), false},
+		{"http-forbidden", Http, "example.com:443", httpProxy(http.StatusForbidden), true},
+		{"socks5-domain", Socks, "example.com:443", socks5Proxy(0x00), false},
+		{"socks5-ipv4", Mixed, "1.2.3.4:80", socks5Proxy(0x00), false},
+		{"socks5-refused", Socks, "example.com:443", socks5Proxy(0x05), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			errs := make(chan error, 1)
+			go func() {
+				c, err := ln.Accept()
+				if err != nil {
+					errs <- err
+					return
+				}
+				defer c.Close()
+				errs <- tt.serve(c, tt.addr)
+			}()
+
+			n := &Node{
+				listenPort:     int32(ln.Addr().(*net.TCPAddr).Port),
+				listenProtocol: tt.protocol,
+				running:        1,
+			}
+			conn, err := n.Dial("tcp", tt.addr)
+			if tt.target {
+				if !errors.Is(err, ErrTarget) {
+					t.Fatalf("want ErrTarget, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer conn.Close()
+			//代理在握手响应后立即发送的数据不能丢失
+			buf := make([]byte, 5)
+			if _, err = io.ReadFull(conn, buf); err != nil || string(buf) != "hello" {
+				t.Fatalf("tunnel data: %q %v", buf, err)
+			}
+			if err = <-errs; err != nil {
+				t.Fatalf("proxy: %v", err)
+			}
+		})
+	}
+}
+
+// httpProxy 模拟http代理,校验CONNECT请求,返回code,成功时紧跟着发送隧道数据
+func httpProxy(code int) func(c net.Conn, addr string) error {
+	return func(c net.Conn, addr string) error {
+		req, err := http.ReadRequest(bufio.NewReader(c))
+		if err != nil {
+			return err
+		}
+		if req.Method != http.MethodConnect || req.Host != addr || req.RequestURI != addr {
+			return errors.New("unexpected request: " + req.Method + " " + req.RequestURI)
+		}
+		resp := fmt.Sprintf("HTTP/1.1 %d %s\r\nContent-Length: 0\r\n\r\n", code, http.StatusText(code))
+		if code == http.StatusOK {
+			resp = "HTTP/1.1 200 Connection established\r\n\r\nhello"
+		}
+		_, err = c.Write([]byte(resp))
+		return err
+	}
+}
+
+// socks5Proxy 模拟socks5代理,校验请求的目标地址,返回code,成功时紧跟着发送隧道数据
+func socks5Proxy(code byte) func(c net.Conn, addr string) error {
+	return func(c net.Conn, addr string) error {
+		buf := make([]byte, 3)
+		if _, err := io.ReadFull(c, buf); err != nil {
+			return err
+		}
+		if _, err := c.Write([]byte{0x05, 0x00}); err != nil {
+			return err
+		}
+		buf = make([]byte, 4)
+		if _, err := io.ReadFull(c, buf); err != nil {
+			return err
+		}
+		host, port, err := readSocks5Addr(c, buf[3])
+		if err != nil {
+			return err
+		}
+		if target := net.JoinHostPort(host, strconv.Itoa(port)); target != addr {
+			return errors.New("unexpected target: " + target)
+		}
+		resp := []byte{0x05, code, 0x00, 0x01, 127, 0, 0, 1}
+		resp = binary.BigEndian.AppendUint16(resp, 1080)
+		if code == 0x00 {
+			resp = append(resp, "hello"...)
+		}
+		_, err = c.Write(resp)
+		return err
+	}
+}
+
+func TestProbeInbound(t *testing.T) {
+	serve := func(reply string) int {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ln.Close() })
+		go func() {
+			for {
+				c, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				c.Read(make([]byte, 64))
+				c.Write([]byte(reply))
+				c.Close()
+			}
+		}()
+		return ln.Addr().(*net.TCPAddr).Port
+	}
+	socks := serve("\x05\x00")
+	httpPort := serve("HTTP/1.1 400 Bad Request\r\nContent-Length: 0\r\n\r\n")
+	closed := serve("")
+
+	tests := []struct {
+		name     string
+		protocol string
+		port     int
+		ok       bool
+	}{
+		{"socks", Socks, socks, true},
+		{"mixed", Mixed, socks, true},
+		{"http", Http, httpPort, true},
+		{"socks-on-http", Socks, httpPort, false},
+		{"http-on-socks", Http, socks, false},
+		{"closed", Mixed, closed, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := probeInbound(tt.protocol, tt.port, time.Second)
+			if (err == nil) != tt.ok {
+				t.Fatalf("want ok %v, got: %v", tt.ok, err)
+			}
+		})
+	}
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/injoyai/conv"
@@ -19,7 +23,10 @@ func main() {
 		//xray_pool.WithNode(s),
 	)
 	defer p.Close()
-	go p.Run()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	go func() { logs.PrintErr(p.Run(ctx)) }()
 	<-p.Started()
 
 	for i := 0; i < 1; i++ {
@@ -0,0 +1,507 @@
+package xray_pool
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/injoyai/conv"
+	"github.com/injoyai/logs"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ExportGroup     = "xray-pool" //导出配置中代理组的名称
+	exportMixedPort = 7890        //导出配置中本地监听的端口
+)
+
+// ExportClash 导出当前健康的节点为Clash(mihomo)配置,包含一个选择所有节点的代理组
+// 节点名称带上测得的延迟,不支持转换的节点会被跳过
+func (p *Pool) ExportClash(w io.Writer) error {
+	nodes := p.healthy()
+	proxies := make([]map[string]any, 0, len(nodes))
+	names := make([]string, 0, len(nodes))
+	used := map[string]bool{}
+	for _, n := range nodes {
+		name := exportName(n, used)
+		m, err := clashExport(n, name)
+		if err != nil {
+			logs.Warn(err)
+			continue
+		}
+		proxies = append(proxies, m)
+		names = append(names, name)
+	}
+	if len(proxies) == 0 {
+		return ErrNoValidNode
+	}
+
+	cfg := clashConfig{
+		MixedPort: exportMixedPort,
+		Mode:      "rule",
+		LogLevel:  "info",
+		Proxies:   proxies,
+		ProxyGroups: []clashGroup{{
+			Name:    ExportGroup,
+			Type:    "select",
+			Proxies: names,
+		}},
+		Rules: []string{"MATCH," + ExportGroup},
+	}
+	e := yaml.NewEncoder(w)
+	e.SetIndent(2)
+	if err := e.Encode(cfg); err != nil {
+		return err
+	}
+	return e.Close()
+}
+
+// ExportSingBox 导出当前健康的节点为sing-box配置,包含一个选择所有节点的selector
+// 节点名称带上测得的延迟,不支持转换的节点会被跳过
+func (p *Pool) ExportSingBox(w io.Writer) error {
+	nodes := p.healthy()
+	outbounds := make([]map[string]any, 0, len(nodes)+2)
+	names := make([]string, 0, len(nodes))
+	used := map[string]bool{}
+	for _, n := range nodes {
+		name := exportName(n, used)
+		m, err := singBoxExport(n, name)
+		if err != nil {
+			logs.Warn(err)
+			continue
+		}
+		outbounds = append(outbounds, m)
+		names = append(names, name)
+	}
+	if len(outbounds) == 0 {
+		return ErrNoValidNode
+	}
+
+	selector := map[string]any{
+		"type":      "selector",
+		"tag":       ExportGroup,
+		"outbounds": names,
+		"default":   names[0],
+	}
+	outbounds = append([]map[string]any{selector}, outbounds...)
+	outbounds = append(outbounds, map[string]any{"type": "direct", "tag": "direct"})
+
+	cfg := map[string]any{
+		"log": map[string]any{"level": "info"},
+		"inbounds": []map[string]any{{
+			"type":        Mixed,
+			"tag":         "mixed-in",
+			"listen":      "127.0.0.1",
+			"listen_port": exportMixedPort,
+		}},
+		"outbounds": outbounds,
+		"route":     map[string]any{"final": ExportGroup},
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	e.SetEscapeHTML(false)
+	return e.Encode(cfg)
+}
+
+// healthy 已经放入代理池并且健康的节点,按延迟排序
+func (p *Pool) healthy() []*Node {
+	return p.members().Sort(func(a, b *Node) bool {
+		return a.spend() < b.spend()
+	})
+}
+
+// exportName 导出的节点名称,带上延迟,重名的加上序号
+func exportName(n *Node, used map[string]bool) string {
+	latency := n.Latency()
+	if latency <= 0 {
+		latency = n.checkSpend
+	}
+	remark := conv.Select(n.Remark() == "", fmt.Sprintf("%s:%d", n.Hostname(), n.Port()), n.Remark())
+	name := fmt.Sprintf("%s [%dms]", remark, latency.Round(time.Millisecond).Milliseconds())
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("%s [%dms] #%d", remark, latency.Round(time.Millisecond).Milliseconds(), i)
+	}
+	used[name] = true
+	return name
+}
+
+type clashConfig struct {
+	MixedPort   int              `yaml:"mixed-port"`
+	AllowLan    bool             `yaml:"allow-lan"`
+	Mode        string           `yaml:"mode"`
+	LogLevel    string           `yaml:"log-level"`
+	Proxies     []map[string]any `yaml:"proxies"`
+	ProxyGroups []clashGroup     `yaml:"proxy-groups"`
+	Rules       []string         `yaml:"rules"`
+}
+
+type clashGroup struct {
+	Name    string   `yaml:"name"`
+	Type    string   `yaml:"type"`
+	Proxies []string `yaml:"proxies"`
+}
+
+// clashProxier 不能通过xray配置转换的节点,自行生成Clash的proxy
+type clashProxier interface {
+	clashProxy(name string) map[string]any
+}
+
+// singBoxOutbounder 不能通过xray配置转换的节点,自行生成sing-box的outbound
+type singBoxOutbounder interface {
+	singBoxOutbound(tag string) map[string]any
+}
+
+// clashExport 节点转换成Clash的proxy
+func clashExport(n *Node, name string) (map[string]any, error) {
+	if c, ok := n.Vnexter.(clashProxier); ok {
+		return c.clashProxy(name), nil
+	}
+	s, ss := n.Settings(), n.StreamSettings()
+	if s == nil {
+		return nil, fmt.Errorf("invalid node: %s", name)
+	}
+	if ss == nil {
+		ss = &StreamSettings{Network: "tcp", Security: None}
+	}
+	m := map[string]any{
+		"name":   name,
+		"server": n.Hostname(),
+		"port":   n.Port(),
+		"udp":    true,
+	}
+
+	switch n.Protocol() {
+	case Vmess, Vless:
+		if len(s.Vnext) == 0 || len(s.Vnext[0].Users) == 0 {
+			return nil, fmt.Errorf("invalid %s node: %s", n.Protocol(), name)
+		}
+		u := s.Vnext[0].Users[0]
+		m["type"] = n.Protocol()
+		m["uuid"] = u.ID
+		if n.Protocol() == Vmess {
+			m["alterId"] = u.AlterId
+			m["cipher"] = conv.Select(u.Security == "", "auto", u.Security)
+		} else if u.Flow != "" {
+			m["flow"] = u.Flow
+		}
+
+	case Trojan:
+		if len(s.Servers) == 0 {
+			return nil, fmt.Errorf("invalid trojan node: %s", name)
+		}
+		m["type"] = Trojan
+		m["password"] = s.Servers[0].Password
+
+	case Shadowsocks:
+		if len(s.Servers) == 0 {
+			return nil, fmt.Errorf("invalid shadowsocks node: %s", name)
+		}
+		m["type"] = "ss"
+		m["cipher"] = s.Servers[0].Method
+		m["password"] = s.Servers[0].Password
+		if ss.Network == "ws" {
+			//xray的ss只支持v2ray-plugin转换来的websocket
+			opts := map[string]any{"mode": "websocket"}
+			if ss.WsSettings != nil {
+				opts["host"] = ss.WsSettings.Host
+				opts["path"] = ss.WsSettings.Path
+			}
+			if ss.Security == "tls" {
+				opts["tls"] = true
+			}
+			m["plugin"] = "v2ray-plugin"
+			m["plugin-opts"] = opts
+		}
+		return m, nil
+
+	case Socks, Http:
+		m["type"] = conv.Select(n.Protocol() == Socks, "socks5", "http")
+		if len(s.Servers) > 0 && len(s.Servers[0].Users) > 0 {
+			m["username"] = s.Servers[0].Users[0].User
+			m["password"] = s.Servers[0].Users[0].Pass
+		}
+		if ss.Security == "tls" {
+			m["tls"] = true
+			m["skip-cert-verify"] = ss.TlsSettings != nil && ss.TlsSettings.AllowInsecure
+		}
+		return m, nil
+
+	case Wireguard:
+		if len(s.Peers) == 0 {
+			return nil, fmt.Errorf("invalid wireguard node: %s", name)
+		}
+		m["type"] = Wireguard
+		m["private-key"] = s.SecretKey
+		m["public-key"] = s.Peers[0].PublicKey
+		if s.Peers[0].PreSharedKey != "" {
+			m["pre-shared-key"] = s.Peers[0].PreSharedKey
+		}
+		for _, addr := range s.Address {
+			ip, _, _ := strings.Cut(addr, "/")
+			m[conv.Select(strings.Contains(ip, ":"), "ipv6", "ip")] = ip
+		}
+		if s.MTU > 0 {
+			m["mtu"] = s.MTU
+		}
+		if len(s.Reserved) > 0 {
+			m["reserved"] = s.Reserved
+		}
+		return m, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported clash export protocol: %s(%s)", n.Protocol(), name)
+	}
+
+	//传输方式
+	switch ss.Network {
+	case "", "tcp":
+		if ss.TcpSettings != nil && ss.TcpSettings.Header != nil && ss.TcpSettings.Header.Type == "http" {
+			opts := map[string]any{}
+			if req := ss.TcpSettings.Header.Request; req != nil {
+				opts["path"] = req.Path
+				for k, v := range req.Headers {
+					if strings.EqualFold(k, "Host") {
+						opts["headers"] = map[string][]string{"Host": v}
+					}
+				}
+			}
+			m["network"] = "http"
+			m["http-opts"] = opts
+		}
+	case "ws":
+		m["network"] = "ws"
+		if ss.WsSettings != nil {
+			m["ws-opts"] = clashWsOpts(ss.WsSettings.Path, ss.WsSettings.Host)
+		}
+	case "httpupgrade":
+		m["network"] = "ws"
+		opts := map[string]any{"v2ray-http-upgrade": true}
+		if ss.HttpupgradeSettings != nil {
+			opts = clashWsOpts(ss.HttpupgradeSettings.Path, ss.HttpupgradeSettings.Host)
+			opts["v2ray-http-upgrade"] = true
+		}
+		m["ws-opts"] = opts
+	case "grpc":
+		m["network"] = "grpc"
+		if ss.GrpcSettings != nil {
+			m["grpc-opts"] = map[string]any{"grpc-service-name": ss.GrpcSettings.ServiceName}
+		}
+	case "h2", "http":
+		m["network"] = "h2"
+		if ss.HttpSettings != nil {
+			m["h2-opts"] = map[string]any{"host": ss.HttpSettings.Host, "path": ss.HttpSettings.Path}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported clash export network: %s(%s)", ss.Network, name)
+	}
+
+	//安全类型,trojan的服务器名称字段是sni,其他是servername
+	sniKey := conv.Select(n.Protocol() == Trojan, "sni", "servername")
+	switch ss.Security {
+	case "tls":
+		m["tls"] = true
+		if t := ss.TlsSettings; t != nil {
+			if t.ServerName != "" {
+				m[sniKey] = t.ServerName
+			}
+			if len(t.Alpn) > 0 {
+				m["alpn"] = t.Alpn
+			}
+			if t.Fingerprint != "" {
+				m["client-fingerprint"] = t.Fingerprint
+			}
+			m["skip-cert-verify"] = t.AllowInsecure
+		}
+	case "reality":
+		m["tls"] = true
+		if r := ss.RealitySettings; r != nil {
+			m[sniKey] = r.ServerName
+			m["client-fingerprint"] = conv.Select(r.Fingerprint == "", "chrome", r.Fingerprint)
+			m["reality-opts"] = map[string]any{"public-key": r.PublicKey, "short-id": r.ShortID}
+		}
+	}
+	return m, nil
+}
+
+func clashWsOpts(path, host string) map[string]any {
+	opts := map[string]any{"path": conv.Select(path == "", "/", path)}
+	if host != "" {
+		opts["headers"] = map[string]string{"Host": host}
+	}
+	return opts
+}
+
+// singBoxExport 节点转换成sing-box的outbound
+func singBoxExport(n *Node, name string) (map[string]any, error) {
+	if o, ok := n.Vnexter.(singBoxOutbounder); ok {
+		return o.singBoxOutbound(name), nil
+	}
+	s, ss := n.Settings(), n.StreamSettings()
+	if s == nil {
+		return nil, fmt.Errorf("invalid node: %s", name)
+	}
+	if ss == nil {
+		ss = &StreamSettings{Network: "tcp", Security: None}
+	}
+	m := map[string]any{
+		"tag":         name,
+		"server":      n.Hostname(),
+		"server_port": n.Port(),
+	}
+
+	switch n.Protocol() {
+	case Vmess, Vless:
+		if len(s.Vnext) == 0 || len(s.Vnext[0].Users) == 0 {
+			return nil, fmt.Errorf("invalid %s node: %s", n.Protocol(), name)
+		}
+		u := s.Vnext[0].Users[0]
+		m["type"] = n.Protocol()
+		m["uuid"] = u.ID
+		if n.Protocol() == Vmess {
+			m["alter_id"] = u.AlterId
+			m["security"] = conv.Select(u.Security == "", "auto", u.Security)
+		} else if u.Flow != "" {
+			m["flow"] = u.Flow
+		}
+
+	case Trojan:
+		if len(s.Servers) == 0 {
+			return nil, fmt.Errorf("invalid trojan node: %s", name)
+		}
+		m["type"] = Trojan
+		m["password"] = s.Servers[0].Password
+
+	case Shadowsocks:
+		if len(s.Servers) == 0 {
+			return nil, fmt.Errorf("invalid shadowsocks node: %s", name)
+		}
+		m["type"] = Shadowsocks
+		m["method"] = s.Servers[0].Method
+		m["password"] = s.Servers[0].Password
+		if ss.Network == "ws" {
+			opts := []string{"mode=websocket"}
+			if ss.WsSettings != nil {
+				if ss.WsSettings.Host != "" {
+					opts = append(opts, "host="+ss.WsSettings.Host)
+				}
+				if ss.WsSettings.Path != "" {
+					opts = append(opts, "path="+ss.WsSettings.Path)
+				}
+			}
+			if ss.Security == "tls" {
+				opts = append(opts, "tls")
+			}
+			m["plugin"] = "v2ray-plugin"
+			m["plugin_opts"] = strings.Join(opts, ";")
+		}
+		return m, nil
+
+	case Socks, Http:
+		m["type"] = n.Protocol()
+		if n.Protocol() == Socks {
+			m["version"] = "5"
+		}
+		if len(s.Servers) > 0 && len(s.Servers[0].Users) > 0 {
+			m["username"] = s.Servers[0].Users[0].User
+			m["password"] = s.Servers[0].Users[0].Pass
+		}
+		if ss.Security == "tls" {
+			m["tls"] = map[string]any{
+				"enabled":  true,
+				"insecure": ss.TlsSettings != nil && ss.TlsSettings.AllowInsecure,
+			}
+		}
+		return m, nil
+
+	case Wireguard:
+		if len(s.Peers) == 0 {
+			return nil, fmt.Errorf("invalid wireguard node: %s", name)
+		}
+		m["type"] = Wireguard
+		m["local_address"] = s.Address
+		m["private_key"] = s.SecretKey
+		m["peer_public_key"] = s.Peers[0].PublicKey
+		if s.Peers[0].PreSharedKey != "" {
+			m["pre_shared_key"] = s.Peers[0].PreSharedKey
+		}
+		if s.MTU > 0 {
+			m["mtu"] = s.MTU
+		}
+		if len(s.Reserved) > 0 {
+			m["reserved"] = s.Reserved
+		}
+		return m, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported sing-box export protocol: %s(%s)", n.Protocol(), name)
+	}
+
+	//传输方式
+	switch ss.Network {
+	case "", "tcp":
+		if ss.TcpSettings != nil && ss.TcpSettings.Header != nil && ss.TcpSettings.Header.Type == "http" {
+			return nil, fmt.Errorf("unsupported sing-box export tcp http header: %s", name)
+		}
+	case "ws":
+		t := map[string]any{"type": "ws"}
+		if ss.WsSettings != nil {
+			t["path"] = conv.Select(ss.WsSettings.Path == "", "/", ss.WsSettings.Path)
+			if ss.WsSettings.Host != "" {
+				t["headers"] = map[string]string{"Host": ss.WsSettings.Host}
+			}
+		}
+		m["transport"] = t
+	case "httpupgrade":
+		t := map[string]any{"type": "httpupgrade"}
+		if ss.HttpupgradeSettings != nil {
+			t["path"] = ss.HttpupgradeSettings.Path
+			t["host"] = ss.HttpupgradeSettings.Host
+		}
+		m["transport"] = t
+	case "grpc":
+		t := map[string]any{"type": "grpc"}
+		if ss.GrpcSettings != nil {
+			t["service_name"] = ss.GrpcSettings.ServiceName
+		}
+		m["transport"] = t
+	case "h2", "http":
+		t := map[string]any{"type": "http"}
+		if ss.HttpSettings != nil {
+			t["host"] = ss.HttpSettings.Host
+			t["path"] = ss.HttpSettings.Path
+		}
+		m["transport"] = t
+	default:
+		return nil, fmt.Errorf("unsupported sing-box export network: %s(%s)", ss.Network, name)
+	}
+
+	//安全类型
+	switch ss.Security {
+	case "tls":
+		tls := map[string]any{"enabled": true}
+		if t := ss.TlsSettings; t != nil {
+			if t.ServerName != "" {
+				tls["server_name"] = t.ServerName
+			}
+			if len(t.Alpn) > 0 {
+				tls["alpn"] = t.Alpn
+			}
+			if t.Fingerprint != "" {
+				tls["utls"] = map[string]any{"enabled": true, "fingerprint": t.Fingerprint}
+			}
+			tls["insecure"] = t.AllowInsecure
+		}
+		m["tls"] = tls
+	case "reality":
+		tls := map[string]any{"enabled": true}
+		if r := ss.RealitySettings; r != nil {
+			tls["server_name"] = r.ServerName
+			tls["utls"] = map[string]any{"enabled": true, "fingerprint": conv.Select(r.Fingerprint == "", "chrome", r.Fingerprint)}
+			tls["reality"] = map[string]any{"enabled": true, "public_key": r.PublicKey, "short_id": r.ShortID}
+		}
+		m["tls"] = tls
+	}
+	return m, nil
+}
@@ -0,0 +1,222 @@
+package xray_pool
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/injoyai/logs"
+)
+
+// NewGateway 新建网关,在一个端口上同时提供http(CONNECT和普通代理)和socks5代理
+// 每个客户端连接从代理池中获取一个节点进行转发
+func NewGateway(p *Pool, addr string) *Gateway {
+	return &Gateway{
+		pool:    p,
+		addr:    addr,
+		Timeout: DefaultTimeout * 2,
+	}
+}
+
+type Gateway struct {
+	pool    *Pool
+	addr    string
+	Timeout time.Duration //获取节点和连接目标地址的超时时间
+
+	mu sync.Mutex
+	ln net.Listener
+}
+
+// ListenAndServe 监听地址并开始服务,阻塞直到Close
+func (g *Gateway) ListenAndServe() error {
+	ln, err := net.Listen("tcp", g.addr)
+	if err != nil {
+		return err
+	}
+	return g.Serve(ln)
+}
+
+// Serve 在ln上开始服务,阻塞直到Close
+func (g *Gateway) Serve(ln net.Listener) error {
+	g.mu.Lock()
+	g.ln = ln
+	g.mu.Unlock()
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go g.handle(c)
+	}
+}
+
+func (g *Gateway) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.ln == nil {
+		return nil
+	}
+	return g.ln.Close()
+}
+
+func (g *Gateway) handle(c net.Conn) {
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(g.Timeout))
+	r := bufio.NewReader(c)
+	b, err := r.Peek(1)
+	if err != nil {
+		return
+	}
+	if b[0] == 0x05 {
+		err = g.handleSocks5(c, r)
+	} else {
+		err = g.handleHttp(c, r)
+	}
+	if err != nil {
+		logs.Debug(err)
+	}
+}
+
+// dial 从代理池获取节点并连接目标地址,连接完成后立即归还节点
+// 只有本地入站和握手失败记为节点失败,目标地址连接失败不影响节点的熔断
+func (g *Gateway) dial(addr string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), g.Timeout)
+	defer cancel()
+	var conn net.Conn
+	err := g.pool.DoContext(ctx, func(ctx context.Context, n *Node) (err error) {
+		conn, err = n.DialContext(ctx, "tcp", addr)
+		switch {
+		case err == nil:
+			n.Success()
+		case !errors.Is(err, ErrTarget):
+			n.Fail("")
+		}
+		return
+	})
+	return conn, err
+}
+
+func (g *Gateway) handleSocks5(c net.Conn, r *bufio.Reader) error {
+	//协商认证方式,只支持无认证
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	methods := make([]byte, buf[1])
+	if _, err := io.ReadFull(r, methods); err != nil {
+		return err
+	}
+	if _, err := c.Write([]byte{0x05, 0x00}); err != nil {
+		return err
+	}
+
+	//读取请求
+	buf = make([]byte, 4)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	host, port, err := readSocks5Addr(r, buf[3])
+	if err != nil {
+		return err
+	}
+	if buf[1] != 0x01 {
+		//只支持CONNECT
+		c.Write([]byte{0x05, 0x07, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		return errors.New("socks5 command not supported: " + strconv.Itoa(int(buf[1])))
+	}
+
+	remote, err := g.dial(net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		c.Write([]byte{0x05, 0x01, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		return err
+	}
+	defer remote.Close()
+	if _, err = c.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0}); err != nil {
+		return err
+	}
+	return g.pipe(c, r, remote)
+}
+
+func (g *Gateway) handleHttp(c net.Conn, r *bufio.Reader) error {
+	req, err := http.ReadRequest(r)
+	if err != nil {
+		return err
+	}
+
+	if req.Method == http.MethodConnect {
+		remote, err := g.dial(req.Host)
+		if err != nil {
+			c.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+			return err
+		}
+		defer remote.Close()
+		if _, err = c.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+			return err
+		}
+		return g.pipe(c, r, remote)
+	}
+
+	//普通代理,一个连接只转发一个请求
+	addr := req.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), "80")
+	}
+	remote, err := g.dial(addr)
+	if err != nil {
+		c.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+		return err
+	}
+	defer remote.Close()
+	req.Header.Del("Proxy-Connection")
+	req.Header.Del("Proxy-Authorization")
+	req.Header.Set("Connection", "close")
+	if err = req.Write(remote); err != nil {
+		return err
+	}
+	return g.pipe(c, r, remote)
+}
+
+// pipe 双向转发数据,r为客户端连接已经缓存的数据
+// 一个方向读完后只关闭对端的写,等待另一个方向转发完成,出错时关闭两端
+func (g *Gateway) pipe(c net.Conn, r *bufio.Reader, remote net.Conn) error {
+	c.SetDeadline(time.Time{})
+	errChan := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(remote, r)
+		closeWrite(remote)
+		errChan <- err
+	}()
+	go func() {
+		_, err := io.Copy(c, remote)
+		closeWrite(c)
+		errChan <- err
+	}()
+	err := <-errChan
+	if err != nil {
+		c.Close()
+		remote.Close()
+	}
+	if err2 := <-errChan; err == nil {
+		err = err2
+	}
+	return err
+}
+
+// closeWrite 关闭连接的写,不支持半关闭的连接直接关闭
+func closeWrite(c net.Conn) {
+	if cw, ok := c.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+		return
+	}
+	c.Close()
+}
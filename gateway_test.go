@@ -0,0 +1,55 @@
+package xray_pool
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestPipeHalfClose(t *testing.T) {
+	//返回一对已经连接的tcp连接
+	pair := func() (*net.TCPConn, *net.TCPConn) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		a, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ln.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a.(*net.TCPConn), b.(*net.TCPConn)
+	}
+	client, c := pair()
+	remote, upstream := pair()
+	defer client.Close()
+	defer upstream.Close()
+
+	g := &Gateway{}
+	go func() {
+		defer c.Close()
+		defer remote.Close()
+		g.pipe(c, bufio.NewReader(c), remote)
+	}()
+	//上游读完请求后才响应
+	go func() {
+		defer upstream.Close()
+		if bs, err := io.ReadAll(upstream); err != nil || string(bs) != "request" {
+			t.Errorf("upstream read: %q %v", bs, err)
+			return
+		}
+		upstream.Write([]byte("response"))
+	}()
+
+	client.Write([]byte("request"))
+	client.CloseWrite()
+	bs, err := io.ReadAll(client)
+	if err != nil || string(bs) != "response" {
+		t.Fatalf("client read: %q %v", bs, err)
+	}
+}
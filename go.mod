@@ -7,6 +7,7 @@ require (
 	github.com/injoyai/base v1.2.17
 	github.com/injoyai/conv v1.2.5
 	github.com/injoyai/logs v1.0.12
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -22,5 +23,4 @@ require (
 	golang.org/x/net v0.39.0 // indirect
 	golang.org/x/sys v0.32.0 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,103 @@
+package xray_pool
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStartWithoutNodes(t *testing.T) {
+	p := New(WithConfigDir(t.TempDir() + "/"))
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatalf("stop before start: %v", err)
+	}
+	//启动失败后可以重新启动
+	for i := 0; i < 2; i++ {
+		started := p.Started()
+		if err := p.Start(context.Background()); err != ErrNoValidNode {
+			t.Fatalf("start %d: want ErrNoValidNode, got: %v", i, err)
+		}
+		select {
+		case <-started:
+		default:
+			t.Fatal("started not closed")
+		}
+	}
+	if err := p.Run(context.Background()); err != ErrNoValidNode {
+		t.Fatalf("run: want ErrNoValidNode, got: %v", err)
+	}
+}
+
+// Stop需要等待进行中的Start结束,Start发现已经Stop后返回ErrPoolClosed
+func TestStopDuringStart(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer s.Close()
+	p := New(WithSubscribe(s.URL), WithConfigDir(t.TempDir()+"/"))
+
+	errs := make(chan error, 1)
+	go func() { errs <- p.Start(context.Background()) }()
+	time.Sleep(100 * time.Millisecond)
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	select {
+	case err := <-errs:
+		if err != ErrPoolClosed {
+			t.Fatalf("want ErrPoolClosed, got: %v", err)
+		}
+	default:
+		t.Fatal("stop returned before start")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running || p.cancel != nil {
+		t.Fatal("background tasks started after stop")
+	}
+}
+
+// Stop等待借出的节点归还后再停止节点
+func TestStopWaitsLeases(t *testing.T) {
+	p, nodes := testPool(t, 1)
+	p.mu.Lock()
+	p.running = true
+	p.done = make(chan struct{})
+	p.mu.Unlock()
+	n, err := p.TryGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	returned := make(chan struct{})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(returned)
+		p.Put(n)
+	}()
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	select {
+	case <-returned:
+	default:
+		t.Fatal("stop returned before the node was returned")
+	}
+	if p.Len() != 0 || nodes[0].Admitted() {
+		t.Fatalf("pool not cleared, len: %d", p.Len())
+	}
+
+	//ctx结束时不再等待
+	p, _ = testPool(t, 1)
+	p.mu.Lock()
+	p.running = true
+	p.done = make(chan struct{})
+	p.mu.Unlock()
+	p.TryGet()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := p.Stop(ctx); err == nil {
+		t.Fatal("want ctx error")
+	}
+}
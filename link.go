@@ -0,0 +1,197 @@
+package xray_pool
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/injoyai/conv"
+)
+
+// vmessLink 生成vmess链接,base64编码的json
+func vmessLink(name, server string, port int, uuid string, alterID int, cipher string, t transport) (string, error) {
+	bs, err := json.Marshal(map[string]any{
+		"v":    "2",
+		"ps":   name,
+		"add":  server,
+		"port": port,
+		"id":   uuid,
+		"aid":  alterID,
+		"scy":  conv.Select(cipher == "", "auto", cipher),
+		"net":  conv.Select(t.Network == "", "tcp", t.Network),
+		"type": conv.Select(t.HeaderType == "", None, t.HeaderType),
+		"host": t.Host,
+		"path": t.Path,
+		"tls":  conv.Select(t.Security == "tls", "tls", ""),
+		"sni":  t.SNI,
+		"alpn": t.Alpn,
+		"fp":   t.Fingerprint,
+	})
+	if err != nil {
+		return "", err
+	}
+	return "vmess://" + base64.StdEncoding.EncodeToString(bs), nil
+}
+
+// queryLink 生成vless和trojan链接,参数在query中,extra为协议相关的参数,例如vless的flow和encryption
+func queryLink(protocol, name, server string, port int, user string, extra map[string]string, t transport) string {
+	q := url.Values{}
+	set := func(k, v string) {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	if protocol == Vless {
+		q.Set("encryption", None)
+	}
+	for k, v := range extra {
+		set(k, v)
+	}
+	set("security", t.Security)
+	set("type", t.Network)
+	set("headerType", t.HeaderType)
+	set("host", t.Host)
+	if t.Network == "grpc" {
+		set("serviceName", t.Path)
+	} else {
+		set("path", t.Path)
+	}
+	set("mode", t.Mode)
+	set("sni", t.SNI)
+	set("alpn", t.Alpn)
+	set("fp", t.Fingerprint)
+	set("pbk", t.PublicKey)
+	set("sid", t.ShortID)
+	set("spx", t.SpiderX)
+	set("pqv", t.Mldsa64Verify)
+	if t.AllowInsecure {
+		q.Set("allowInsecure", "1")
+	}
+	u := url.URL{
+		Scheme:   protocol,
+		User:     url.User(user),
+		Host:     net.JoinHostPort(server, conv.String(port)),
+		RawQuery: q.Encode(),
+	}
+	return u.String() + "#" + url.QueryEscape(name)
+}
+
+// ssLink 生成SIP002格式的ss链接,plugin为 name;k=v;flag 格式
+func ssLink(name, server string, port int, method, password, plugin string) string {
+	userinfo := base64.RawURLEncoding.EncodeToString([]byte(method + ":" + password))
+	link := "ss://" + userinfo + "@" + net.JoinHostPort(server, conv.String(port))
+	if plugin != "" {
+		link += "/?plugin=" + url.QueryEscape(plugin)
+	}
+	return link + "#" + url.QueryEscape(name)
+}
+
+// Linker 可以导出成分享链接的节点配置
+type Linker interface {
+	Link() (string, error)
+}
+
+// Link 导出节点的分享链接
+func (n *Node) Link() (string, error) {
+	if l, ok := n.Vnexter.(Linker); ok {
+		return l.Link()
+	}
+	return "", fmt.Errorf("unsupported export link: %s", n.Protocol())
+}
+
+func (c *VmessConfig) Link() (string, error) {
+	cfg := *c
+	cfg.Version = FlexString(conv.Select(c.Version == "", "2", string(c.Version)))
+	bs, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	return "vmess://" + base64.StdEncoding.EncodeToString(bs), nil
+}
+
+func (c *VlessConfig) Link() (string, error) {
+	if len(c.settings.Vnext) == 0 || len(c.settings.Vnext[0].Users) == 0 {
+		return "", fmt.Errorf("invalid vless config: %s", c.remark)
+	}
+	u := c.settings.Vnext[0].Users[0]
+	return queryLink(Vless, c.remark, c.hostname, c.port, u.ID, map[string]string{
+		"encryption": u.Encryption,
+		"flow":       u.Flow,
+	}, c.transport), nil
+}
+
+func (c *TrojanConfig) Link() (string, error) {
+	if len(c.settings.Servers) == 0 {
+		return "", fmt.Errorf("invalid trojan config: %s", c.remark)
+	}
+	return queryLink(Trojan, c.remark, c.hostname, c.port, c.settings.Servers[0].Password, nil, c.transport), nil
+}
+
+func (c *ShadowsocksConfig) Link() (string, error) {
+	plugin := c.plugin
+	if plugin != "" {
+		keys := make([]string, 0, len(c.pluginOpts))
+		for k := range c.pluginOpts {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			plugin += ";" + k
+			if v := c.pluginOpts[k]; v != "" {
+				plugin += "=" + v
+			}
+		}
+	}
+	return ssLink(c.remark, c.hostname, c.port, c.method, c.password, plugin), nil
+}
+
+func (c *WireguardConfig) Link() (string, error) {
+	s := c.settings
+	if len(s.Peers) == 0 {
+		return "", fmt.Errorf("invalid wireguard config: %s", c.remark)
+	}
+	q := url.Values{}
+	q.Set("publickey", s.Peers[0].PublicKey)
+	q.Set("address", strings.Join(s.Address, ","))
+	if s.Peers[0].PreSharedKey != "" {
+		q.Set("presharedkey", s.Peers[0].PreSharedKey)
+	}
+	if s.Peers[0].KeepAlive > 0 {
+		q.Set("keepalive", conv.String(s.Peers[0].KeepAlive))
+	}
+	if s.MTU > 0 {
+		q.Set("mtu", conv.String(s.MTU))
+	}
+	if len(s.Reserved) > 0 {
+		ls := make([]string, len(s.Reserved))
+		for i, v := range s.Reserved {
+			ls[i] = conv.String(v)
+		}
+		q.Set("reserved", strings.Join(ls, ","))
+	}
+	u := url.URL{
+		Scheme:   "wireguard",
+		User:     url.User(s.SecretKey),
+		Host:     net.JoinHostPort(c.hostname, conv.String(c.port)),
+		RawQuery: q.Encode(),
+	}
+	return u.String() + "#" + url.QueryEscape(c.remark), nil
+}
+
+func (c *ProxyConfig) Link() (string, error) {
+	u := url.URL{
+		Scheme: conv.Select(c.protocol == Socks, "socks5", "http"),
+		Host:   net.JoinHostPort(c.hostname, conv.String(c.port)),
+	}
+	if c.protocol == Http && c.streamSettings != nil && c.streamSettings.Security == "tls" {
+		u.Scheme = "https"
+	}
+	if s := c.settings.Servers; len(s) > 0 && len(s[0].Users) > 0 {
+		u.User = url.UserPassword(s[0].Users[0].User, s[0].Users[0].Pass)
+	}
+	return u.String() + "#" + url.QueryEscape(c.remark), nil
+}
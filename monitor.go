@@ -0,0 +1,147 @@
+package xray_pool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/injoyai/logs"
+)
+
+// WithHealthCheck 设置健康检查间隔,定时对空闲节点执行代理校验(proxyCheck),小于等于0不检查
+// 共享模式下借出中的节点也会检查
+// 校验失败的节点移出代理池,恢复后重新放回
+func WithHealthCheck(interval time.Duration) Option {
+	return func(p *Pool) {
+		p.health = interval
+	}
+}
+
+func (p *Pool) monitorLoop(ctx context.Context) {
+	t := time.NewTicker(p.health)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			p.monitor(ctx)
+		}
+	}
+}
+
+// monitor 检查一遍所有空闲节点
+func (p *Pool) monitor(ctx context.Context) {
+	if p.proxyCheck == nil {
+		return
+	}
+	p.nodeMu.Lock()
+	valid := p.valid.Copy()
+	p.nodeMu.Unlock()
+
+	wg := sync.WaitGroup{}
+	for _, n := range valid {
+		//独占模式下借出中的节点不检查
+		if n.Removed() || (p.shared <= 0 && n.Leases() > 0) {
+			continue
+		}
+		wg.Add(1)
+		go func(n *Node) {
+			defer wg.Done()
+			p.healthCheck(ctx, n)
+		}(n)
+	}
+	wg.Wait()
+
+	//移出检查失败的节点
+	p.filter(func(n *Node) bool { return !n.Unhealthy() })
+}
+
+func (p *Pool) healthCheck(ctx context.Context, n *Node) {
+	//进程已经退出,尝试重新启动
+	if n.Closed() {
+		//先移出代理池,避免重启过程中被借出,借出中的节点归还后再处理
+		p.filter(func(v *Node) bool { return v != n })
+		if n.Leases() > 0 {
+			return
+		}
+		port := n.ListenPort()
+		if port <= 0 {
+			ports := p.allocPorts([]*Node{n})
+			if len(ports) == 0 {
+				n.setUnhealthy(true)
+				return
+			}
+			port = ports[0]
+		}
+		var err error
+		if p.hosted(n) {
+			err = p.startHosted([]*Node{n}, []int{port})
+		} else {
+			err = p.startNode(n, port)
+		}
+		if err != nil {
+			n.setUnhealthy(true)
+			return
+		}
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	spend, err := p.proxyCheck(n)
+	if err != nil {
+		if !n.Unhealthy() {
+			logs.Warn("节点不可用:", n.Origin(), err)
+		}
+		n.setUnhealthy(true)
+		return
+	}
+	n.setLatency(spend)
+	//启动时代理校验失败或者没有分配到端口的节点,第一次检查通过时放入代理池
+	if n.Unhealthy() || !n.Admitted() {
+		logs.Info("节点恢复:", n.Proxy(), "->", n.Origin())
+		n.setUnhealthy(false)
+		p.put(n)
+	}
+}
+
+// Latency 最近一次代理校验的耗时
+func (n *Node) Latency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&n.latency))
+}
+
+func (n *Node) setLatency(spend time.Duration) {
+	atomic.StoreInt64(&n.latency, int64(spend))
+}
+
+// Unhealthy 节点健康检查是否失败
+func (n *Node) Unhealthy() bool {
+	return atomic.LoadUint32(&n.unhealthy) == 1
+}
+
+func (n *Node) setUnhealthy(b bool) {
+	if b {
+		atomic.StoreUint32(&n.unhealthy, 1)
+	} else {
+		atomic.StoreUint32(&n.unhealthy, 0)
+	}
+}
+
+// Admitted 节点是否属于代理池(空闲或借出中)
+func (n *Node) Admitted() bool {
+	return atomic.LoadUint32(&n.admitted) == 1
+}
+
+func (n *Node) setAdmitted(b bool) {
+	if b {
+		atomic.StoreUint32(&n.admitted, 1)
+	} else {
+		atomic.StoreUint32(&n.admitted, 0)
+	}
+}
+
+// Leases 节点当前被借出的次数
+func (n *Node) Leases() int {
+	return int(atomic.LoadInt32(&n.leases))
+}
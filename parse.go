@@ -36,6 +36,24 @@ func ParseVless(raw string) (*VlessConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	q := u.Query()
+
+	t := transport{
+		Network:       q.Get("type"),
+		Security:      q.Get("security"),
+		HeaderType:    q.Get("headerType"),
+		Host:          q.Get("host"),
+		Path:          conv.Select(q.Get("type") == "grpc", q.Get("serviceName"), q.Get("path")),
+		Mode:          q.Get("mode"),
+		SNI:           q.Get("sni"),
+		Alpn:          q.Get("alpn"),
+		Fingerprint:   q.Get("fp"),
+		AllowInsecure: q.Get("allowInsecure") == "1" || q.Get("allowInsecure") == "true",
+		PublicKey:     q.Get("pbk"),
+		ShortID:       q.Get("sid"),
+		SpiderX:       q.Get("spx"),
+		Mldsa64Verify: q.Get("pqv"),
+	}
 
 	return &VlessConfig{
 		remark:   remark,
@@ -50,26 +68,15 @@ func ParseVless(raw string) (*VlessConfig, error) {
 						{
 							ID:         u.User.Username(),
 							AlterId:    0,
-							Encryption: u.Query().Get("encryption"),
-							Flow:       u.Query().Get("flow"),
+							Encryption: conv.Select(q.Get("encryption") == "", None, q.Get("encryption")),
+							Flow:       q.Get("flow"),
 						},
 					},
 				},
 			},
 		},
-		streamSettings: &StreamSettings{
-			Network:  u.Query().Get("type"),
-			Security: u.Query().Get("security"),
-			RealitySettings: RealitySettings{
-				ServerName:    u.Query().Get("sni"),
-				Fingerprint:   u.Query().Get("fp"),
-				Show:          false,
-				PublicKey:     u.Query().Get("pbk"),
-				ShortID:       u.Query().Get("sid"),
-				SpiderX:       u.Query().Get("spx"),
-				Mldsa64Verify: "",
-			},
-		},
+		transport:      t,
+		streamSettings: t.streamSettings(),
 	}, nil
 }
 
@@ -78,6 +85,7 @@ type VlessConfig struct {
 	hostname       string
 	port           int
 	settings       *Settings
+	transport      transport
 	streamSettings *StreamSettings
 }
 
@@ -113,10 +121,11 @@ func (c *VlessConfig) StreamSettings() *StreamSettings {
 ParseVmess
 vmess://eyJwcyI6Ind3dy44NWxhLmNvbfCfh7rwn4e4VVNfNTR8ODc5S0IvcyIsImFkZCI6InNzc3Nzc3Nzc3Nzc2ZmZmZmZmZnaC4yMDMyLnBwLnVhIiwiYWlkIjowLCJpZCI6IjQxNzRiOTVkLTExNWUtNGQzOS1hZGQ2LTFmOGRiOTViYjg2MCIsIm5ldCI6IndzIiwic2N5IjoiYXV0byIsInBvcnQiOjQ0MywidGxzIjoidGxzIiwicGF0aCI6Ii82V2UzVTlEZjFXR3hnRm5vRlB3MSIsImhvc3QiOiJzc3Nzc3Nzc3Nzc3NmZmZmZmZmZ2guMjAzMi5wcC51YSIsInNuaSI6InNzc3Nzc3Nzc3Nzc2ZmZmZmZmZnaC4yMDMyLnBwLnVhIn0=
 {"ps":"www.85la.comUS_54|879KB/s","add":"ssssssssssssfffffffgh.2032.pp.ua","aid":0,"id":"4174b95d-115e-4d39-add6-1f8db95bb860","net":"ws","scy":"auto","port":443,"tls":"tls","path":"/6We3U9Df1WGxgFnoFPw1","host":"ssssssssssssfffffffgh.2032.pp.ua","sni":"ssssssssssssfffffffgh.2032.pp.ua"}
+port,aid 兼容字符串和数字,base64兼容标准,URL安全,有无填充的格式
 */
 func ParseVmess(u string) (*VmessConfig, error) {
 	raw := strings.TrimPrefix(u, "vmess://")
-	data, err := base64.StdEncoding.DecodeString(raw)
+	data, err := decodeBase64(raw)
 	if err != nil {
 		return nil, err
 	}
@@ -126,16 +135,21 @@ func ParseVmess(u string) (*VmessConfig, error) {
 }
 
 type VmessConfig struct {
-	Hostname_ string `json:"host"`
-	Port_     string `json:"port"`
-	UID       string `json:"id"`
-	AlterID   string `json:"aid"`
-	Remark_   string `json:"ps"`
-	Network   string `json:"net"`
-	Path      string `json:"path"`
-	Security  string `json:"scy"`
-	SNI       string `json:"sni"`
-	TLS       string `json:"tls"`
+	Version     FlexString `json:"v"`
+	Remark_     string     `json:"ps"`
+	Address     string     `json:"add"`  // 服务器地址
+	Port_       FlexString `json:"port"` // 服务器端口
+	UID         string     `json:"id"`
+	AlterID     FlexString `json:"aid"`
+	Security    string     `json:"scy"`  // 加密方式
+	Network     string     `json:"net"`  // 传输方式
+	Type        string     `json:"type"` // 伪装类型
+	Host        string     `json:"host"` // 伪装域名
+	Path        string     `json:"path"`
+	TLS         string     `json:"tls"`
+	SNI         string     `json:"sni"`
+	Alpn        string     `json:"alpn"`
+	Fingerprint string     `json:"fp"`
 }
 
 func (c *VmessConfig) Remark() string {
@@ -147,21 +161,21 @@ func (c *VmessConfig) Protocol() string {
 }
 
 func (c *VmessConfig) Hostname() string {
-	return c.Hostname_
+	return c.Address
 }
 
-func (c *VmessConfig) Port() int { return conv.Int(c.Port_) }
+func (c *VmessConfig) Port() int { return conv.Int(string(c.Port_)) }
 
 func (c *VmessConfig) Settings() *Settings {
 	return &Settings{
 		Vnext: []Vnext{
 			{
-				Address: c.Hostname_,
+				Address: c.Address,
 				Port:    c.Port(),
 				Users: []User{{
-					ID:         c.UID,
-					AlterId:    conv.Int(c.AlterID),
-					Encryption: None,
+					ID:       c.UID,
+					AlterId:  conv.Int(string(c.AlterID)),
+					Security: conv.Select(c.Security == "", "auto", c.Security),
 				}},
 			},
 		},
@@ -169,13 +183,36 @@ func (c *VmessConfig) Settings() *Settings {
 }
 
 func (c *VmessConfig) StreamSettings() *StreamSettings {
-	return &StreamSettings{
-		Network:  c.Network,
-		Security: c.Security,
-		RealitySettings: RealitySettings{
-			ServerName: c.SNI,
-		},
+	return transport{
+		Network:     c.Network,
+		Security:    conv.Select(c.TLS == "tls", "tls", None),
+		HeaderType:  c.Type,
+		Host:        c.Host,
+		Path:        c.Path,
+		SNI:         conv.Select(c.SNI == "", c.Host, c.SNI),
+		Alpn:        c.Alpn,
+		Fingerprint: c.Fingerprint,
+	}.streamSettings()
+}
+
+// FlexString 兼容json中的字符串和数字
+type FlexString string
+
+func (s *FlexString) UnmarshalJSON(bs []byte) error {
+	if len(bs) > 0 && bs[0] == '"' {
+		var v string
+		if err := json.Unmarshal(bs, &v); err != nil {
+			return err
+		}
+		*s = FlexString(v)
+		return nil
 	}
+	if string(bs) == "null" {
+		*s = ""
+		return nil
+	}
+	*s = FlexString(bs)
+	return nil
 }
 
 /*
@@ -192,13 +229,17 @@ func ParseTrojan(raw string) (*TrojanConfig, error) {
 	// 拆分备注部分
 	parts := strings.SplitN(raw, "#", 2)
 	link := parts[0]
-	remark := conv.Default("", parts[1:]...)
+	remark := ""
+	if len(parts) > 1 {
+		remark, _ = url.QueryUnescape(parts[1])
+	}
 
 	// 为了能用 url.Parse 解析 user@host:port?query 的结构，补上协议头
 	u, err := url.Parse("trojan://" + link)
 	if err != nil {
 		return nil, err
 	}
+	q := u.Query()
 
 	// password 可能在 User.Username() 中（常见形式），也可能在 query 中（不常见）
 	password := conv.Default(u.User.Username(), u.Query()["password"]...)
@@ -206,10 +247,26 @@ func ParseTrojan(raw string) (*TrojanConfig, error) {
 	// 端口默认为空则用 443
 	port := conv.Select(u.Port() == "", 443, conv.Int(u.Port()))
 
-	// 常用参数：sni / alpn / plugin 等
-	//sni := conv.Default(u.Hostname(), u.Query()["sni"]...)
-	//alpn := u.Query().Get("alpn")
-	//plugin := u.Query().Get("plugin")
+	// 常用参数：sni / alpn / 传输方式等,trojan默认使用tls
+	security := q.Get("security")
+	if security == "" {
+		security = "tls"
+	}
+	t := transport{
+		Network:       q.Get("type"),
+		Security:      security,
+		HeaderType:    q.Get("headerType"),
+		Host:          q.Get("host"),
+		Path:          conv.Select(q.Get("type") == "grpc", q.Get("serviceName"), q.Get("path")),
+		Mode:          q.Get("mode"),
+		SNI:           conv.Select(q.Get("sni") == "", conv.Select(q.Get("peer") == "", q.Get("host"), q.Get("peer")), q.Get("sni")),
+		Alpn:          q.Get("alpn"),
+		Fingerprint:   q.Get("fp"),
+		AllowInsecure: q.Get("allowInsecure") == "1" || q.Get("allowInsecure") == "true",
+		PublicKey:     q.Get("pbk"),
+		ShortID:       q.Get("sid"),
+		SpiderX:       q.Get("spx"),
+	}
 
 	return &TrojanConfig{
 		remark:   remark,
@@ -224,6 +281,8 @@ func ParseTrojan(raw string) (*TrojanConfig, error) {
 				},
 			},
 		},
+		transport:      t,
+		streamSettings: t.streamSettings(),
 	}, nil
 }
 
@@ -232,6 +291,7 @@ type TrojanConfig struct {
 	hostname       string
 	port           int
 	settings       *Settings
+	transport      transport
 	streamSettings *StreamSettings
 }
 
@@ -251,3 +311,13 @@ func (c *TrojanConfig) Settings() *Settings {
 func (c *TrojanConfig) StreamSettings() *StreamSettings {
 	return c.streamSettings
 }
+
+// decodeBase64 解码base64,兼容标准,URL安全,有无填充的格式
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimRight(s, "=")
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
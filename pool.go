@@ -2,28 +2,41 @@ package xray_pool
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"os/exec"
-	"os/signal"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
-	"syscall"
 	"time"
 
 	"github.com/injoyai/base/types"
+	"github.com/injoyai/conv"
 	"github.com/injoyai/logs"
 )
 
 const (
 	DefaultConfigDir = "./config/"
 	DefaultStartPort = 50000
+	DefaultEndPort   = 65535
 	DefaultPoolCap   = 1000
 	DefaultTimeout   = time.Second * 5
+	DefaultStartWait = time.Second * 10
+	DefaultCooldown  = time.Minute
 	ErrInvalidPort   = types.Err("端口被占用")
+	ErrPoolEmpty     = types.Err("pool empty")
+	ErrPoolClosed    = types.Err("pool closed")
+	ErrDeadline      = types.Err("deadline exceeded")
+	ErrPoolRunning   = types.Err("pool already running")
+	ErrNoValidNode   = types.Err("no valid node")
+	ErrNoFreePort    = types.Err("no free port")
+	ErrTarget        = types.Err("connect target failed")
+	errNotReady      = types.Err("process not ready")
 )
 
 var (
@@ -69,20 +82,16 @@ func WithProxyCheck(check CheckFunc) Option {
 	}
 }
 
+// WithPoolCap 设置代理池容量,超出容量的节点会被停止
 func WithPoolCap(cap int) Option {
 	return func(p *Pool) {
-		if cap >= 0 && cap != len(p.pool) {
-			old := p.pool
-			p.pool = make(chan *Node, cap)
-			for n := range old {
-				p.Put(n)
-			}
-			close(old)
+		if cap >= 0 {
+			p.poolCap = cap
 		}
 	}
 }
 
-// WithCmd 设置启动命令
+// WithCmd 设置xray内核的启动命令,其他内核使用默认命令
 // xray run -config
 // v2ray -config
 func WithCmd(cmd []string) Option {
@@ -101,12 +110,15 @@ func New(op ...Option) *Pool {
 	p := &Pool{
 		configDir:  DefaultConfigDir,
 		startPort:  DefaultStartPort,
+		endPort:    DefaultEndPort,
 		nodeFunc:   ByPing,
 		proxyCheck: ByGoogle,
-		pool:       make(chan *Node, DefaultPoolCap),
-		cmd:        XrayCmd,
+		poolCap:    DefaultPoolCap,
+		selector:   RoundRobin(),
+		wake:       make(chan struct{}),
+		cores:      []Core{Xray()},
 		protocol:   Mixed,
-		done:       make(chan struct{}),
+		cooldown:   DefaultCooldown,
 		started:    make(chan struct{}),
 	}
 	for _, o := range op {
@@ -116,90 +128,499 @@ func New(op ...Option) *Pool {
 }
 
 type Pool struct {
-	subscribes []string  //订阅地址
-	nodeUrls   []string  //节点地址
-	configDir  string    //配置目录
-	startPort  int       //起始端口
-	nodeFunc   CheckFunc //检查节点是否可用,ping,tcp,download等
-	proxyCheck CheckFunc //代理请求校验
-	cmd        []string  //启动命令
-	protocol   string    //协议
-
-	pool     chan *Node        //代理池
-	allNodes types.List[*Node] //全部节点
-	valid    types.List[*Node] //有效节点
-	done     chan struct{}     //
-	once     sync.Once
-	started  chan struct{}
+	subscribes  []string  //订阅地址
+	nodeUrls    []string  //节点地址
+	configFiles []string  //xray/v2ray配置文件
+	configDir   string    //配置目录
+	startPort   int       //起始端口
+	endPort     int       //结束端口(包含)
+	nodeFunc    CheckFunc //检查节点是否可用,ping,tcp,download等
+	proxyCheck  CheckFunc //代理请求校验
+	cmd         []string  //启动命令
+	cores       []Core    //代理内核,节点使用第一个支持该协议的内核
+	protocol    string    //协议
+
+	pool      []*Node           //代理池,空闲的节点
+	poolCap   int               //代理池容量
+	poolMu    sync.Mutex        //代理池锁
+	wake      chan struct{}     //有节点放入代理池时关闭并重新创建
+	selector  Selector          //节点选择策略
+	shared    int               //共享模式,每个节点同时借出的次数限制,小于等于0为独占模式
+	maxLeases int               //同时借出的总次数限制,小于等于0不限制
+	host      *hostProcess      //单进程模式,所有节点运行在同一个进程中
+	allNodes  types.List[*Node] //全部节点
+	valid     types.List[*Node] //有效节点
+	leased    int32             //借出的节点数量
+	nodeMu    sync.Mutex        //allNodes和valid的锁
+	portMu    sync.Mutex        //分配端口的锁
+	stable    bool              //固定端口模式
+	portMap   portRecords       //固定端口模式下节点指纹对应的端口
+	pending   map[int]bool      //已经分配,节点还没有启动完成的端口
+	configs   configCache       //已经解析的配置文件
+	configMu  sync.Mutex        //configs的锁
+
+	refresh   time.Duration      //订阅刷新间隔
+	health    time.Duration      //健康检查间隔
+	failLimit int                //连续失败次数限制,达到后熔断
+	cooldown  time.Duration      //熔断冷却时间
+	refreshMu sync.Mutex         //刷新锁,同一时间只有一个刷新
+	cancel    context.CancelFunc //关闭后台任务
+	bg        sync.WaitGroup     //后台任务
+
+	mu       sync.Mutex
+	running  bool          //是否在运行
+	done     chan struct{} //关闭信号,Start时创建,Stop时关闭
+	starting chan struct{} //Start进行中,Start结束时关闭
+	started  chan struct{} //启动完成信号
 }
 
+// Get 获取节点,阻塞直到有可用节点,代理池关闭时返回nil
 func (p *Pool) Get() *Node {
-	node := <-p.pool
-	return node
+	n, _ := p.GetContext(context.Background())
+	return n
+}
+
+// GetContext 获取节点,阻塞直到有可用节点,代理池关闭或ctx结束
+func (p *Pool) GetContext(ctx context.Context) (*Node, error) {
+	return p.acquire(ctx, "")
+}
+
+// GetByKey 按key获取节点,配合ConsistentHash使用时,相同的key会得到相同的节点,节点被借出时等待归还
+func (p *Pool) GetByKey(ctx context.Context, key string) (*Node, error) {
+	return p.acquire(ctx, key)
 }
 
+// TryGet 尝试获取节点,不阻塞,没有可用节点时返回ErrPoolEmpty
+func (p *Pool) TryGet() (*Node, error) {
+	if n := p.take(""); n != nil {
+		return n, nil
+	}
+	select {
+	case <-p.closed():
+		return nil, ErrPoolClosed
+	default:
+		return nil, ErrPoolEmpty
+	}
+}
+
+func (p *Pool) acquire(ctx context.Context, key string) (*Node, error) {
+	for {
+		p.poolMu.Lock()
+		wake := p.wake
+		p.poolMu.Unlock()
+		if n := p.take(key); n != nil {
+			return n, nil
+		}
+		select {
+		case <-wake:
+		case <-p.closed():
+			return nil, ErrPoolClosed
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, ErrDeadline
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// take 通过选择策略从代理池中取出一个节点并记录借出,没有可用节点时返回nil
+// 共享模式下节点不移出代理池,借出次数达到限制的节点不参与选择
+// 一致性哈希按key获取时在代理池的全部可用节点中选择,目标节点不空闲时返回nil,等待归还
+func (p *Pool) take(key string) *Node {
+	var members []*Node
+	if _, ok := p.selector.(*consistentHash); ok && key != "" {
+		members = p.members()
+	}
+
+	p.poolMu.Lock()
+	defer p.poolMu.Unlock()
+	if len(p.pool) == 0 {
+		return nil
+	}
+	if p.maxLeases > 0 && atomic.LoadInt32(&p.leased) >= int32(p.maxLeases) {
+		return nil
+	}
+
+	ls := p.pool
+	if p.shared > 0 {
+		ls = make([]*Node, 0, len(p.pool))
+		for _, v := range p.pool {
+			//半开状态只允许一次探测
+			limit := conv.Select(v.HalfOpen(), 1, p.shared)
+			if v.Leases() < limit {
+				ls = append(ls, v)
+			}
+		}
+	}
+
+	var n *Node
+	if len(members) > 0 {
+		i := p.selector.Select(members, key)
+		if i < 0 || i >= len(members) || !slices.Contains(ls, members[i]) {
+			return nil
+		}
+		n = members[i]
+	} else {
+		if len(ls) == 0 {
+			return nil
+		}
+		i := p.selector.Select(ls, key)
+		if i < 0 || i >= len(ls) {
+			return nil
+		}
+		n = ls[i]
+	}
+	if p.shared <= 0 {
+		i := slices.Index(p.pool, n)
+		p.pool = append(p.pool[:i], p.pool[i+1:]...)
+	}
+
+	atomic.AddInt32(&p.leased, 1)
+	atomic.AddInt32(&n.leases, 1)
+	atomic.StoreInt64(&n.lastUsed, time.Now().UnixNano())
+	return n
+}
+
+// members 属于代理池并且可用的节点,包括借出中的节点
+func (p *Pool) members() types.List[*Node] {
+	return p.nodes().Where(func(i int, n *Node) bool {
+		return n.Admitted() && !n.Closed() && !n.Removed() && !n.Unhealthy() && !n.Broken()
+	})
+}
+
+// Put 归还节点,已经停止的节点不会放回代理池
+// 无论节点是否放回,借出数量都减少了,都需要唤醒等待的调用者
 func (p *Pool) Put(n *Node) {
-	p.pool <- n
+	if n == nil {
+		return
+	}
+	defer p.notify()
+	atomic.AddInt32(&p.leased, -1)
+	atomic.AddInt32(&n.leases, -1)
+	if p.shared > 0 {
+		p.release(n)
+		return
+	}
+	p.put(n)
+}
+
+// release 共享模式下归还节点,节点仍在代理池中,不可用时移出
+func (p *Pool) release(n *Node) {
+	if n.Removed() || n.Closed() || n.Unhealthy() || n.Broken() {
+		p.filter(func(v *Node) bool { return v != n })
+		if n.Removed() && n.Leases() == 0 {
+			n.Stop()
+		}
+		if n.Broken() {
+			p.quarantine(n)
+		}
+	}
 }
 
+func (p *Pool) put(n *Node) {
+	if n == nil {
+		return
+	}
+	if n.Removed() {
+		//节点已经从订阅中移除,归还时停止
+		n.setAdmitted(false)
+		if n.Leases() == 0 {
+			n.Stop()
+		}
+		return
+	}
+	if n.Closed() || n.Unhealthy() {
+		n.setAdmitted(false)
+		return
+	}
+	if n.Broken() {
+		p.quarantine(n)
+		return
+	}
+	p.poolMu.Lock()
+	if slices.Contains(p.pool, n) {
+		p.poolMu.Unlock()
+		return
+	}
+	if len(p.pool) >= p.poolCap {
+		p.poolMu.Unlock()
+		logs.Warn("代理池已满,停止节点:", n.Origin())
+		n.setAdmitted(false)
+		n.Stop()
+		return
+	}
+	p.pool = append(p.pool, n)
+	n.setAdmitted(true)
+	p.poolMu.Unlock()
+	p.notify()
+}
+
+// notify 唤醒等待节点的调用者
+func (p *Pool) notify() {
+	p.poolMu.Lock()
+	defer p.poolMu.Unlock()
+	close(p.wake)
+	p.wake = make(chan struct{})
+}
+
+// filter 过滤代理池中空闲的节点,f返回false的节点移出代理池
+func (p *Pool) filter(f func(n *Node) bool) {
+	p.poolMu.Lock()
+	defer p.poolMu.Unlock()
+	ls := p.pool[:0]
+	for _, n := range p.pool {
+		if f(n) {
+			ls = append(ls, n)
+		} else {
+			n.setAdmitted(false)
+		}
+	}
+	clear(p.pool[len(ls):])
+	p.pool = ls
+}
+
+// Len 代理池中空闲的节点数量
 func (p *Pool) Len() int {
+	p.poolMu.Lock()
+	defer p.poolMu.Unlock()
 	return len(p.pool)
 }
 
+// Started 启动完成信号,每次Start结束(无论成功与否)后关闭
 func (p *Pool) Started() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	return p.started
 }
 
+// closed 关闭信号,未启动时返回nil,即永不关闭
+func (p *Pool) closed() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.done
+}
+
 func (p *Pool) Do(f func(n *Node) error) error {
-	n := p.Get()
+	return p.DoContext(context.Background(), func(ctx context.Context, n *Node) error {
+		return f(n)
+	})
+}
+
+// DoContext 获取节点并执行f,执行完成后归还节点
+func (p *Pool) DoContext(ctx context.Context, f func(ctx context.Context, n *Node) error) error {
+	n, err := p.GetContext(ctx)
+	if err != nil {
+		return err
+	}
 	defer p.Put(n)
-	return f(n)
+	return f(ctx, n)
+}
+
+// Run 启动代理池,阻塞直到ctx结束或者调用Close,然后关闭代理池
+func (p *Pool) Run(ctx context.Context) error {
+	if err := p.Start(ctx); err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+	case <-p.closed():
+	}
+	return p.Stop(context.Background())
 }
 
-func (p *Pool) Run() error {
-	p.Close()
+// Start 获取,解析,校验并启动节点,启动完成后返回,可以在Stop后重新Start
+func (p *Pool) Start(ctx context.Context) (err error) {
+	p.mu.Lock()
+	if p.running {
+		p.mu.Unlock()
+		return ErrPoolRunning
+	}
+	p.running = true
+	p.done = make(chan struct{})
+	p.starting = make(chan struct{})
+	done, starting, started := p.done, p.starting, p.started
+	p.mu.Unlock()
+
+	defer func() {
+		close(started)
+		p.mu.Lock()
+		close(starting)
+		p.starting = nil
+		p.mu.Unlock()
+		if err != nil {
+			p.Stop(context.Background())
+		}
+	}()
+
+	//Stop时取消正在进行的订阅
+	ctx, cancelStart := context.WithCancel(ctx)
+	defer cancelStart()
+	go func() {
+		select {
+		case <-done:
+			cancelStart()
+		case <-ctx.Done():
+		}
+	}()
+
+	p.nodeMu.Lock()
+	p.allNodes = nil
+	p.valid = nil
+	p.nodeMu.Unlock()
 
 	//获取所有节点地址,去重
-	m := p.subscribe()
+	m, err := p.subscribe(ctx)
+	if err != nil {
+		logs.Err(err)
+	}
+	if err = p.aborted(ctx, done); err != nil {
+		return err
+	}
 
 	//解析节点信息
-	p.parse(m)
+	all := p.parse(m)
 
 	//校验节点
-	p.check()
+	valid := p.check(all)
+	if err = p.aborted(ctx, done); err != nil {
+		return err
+	}
 
-	//开始启动
-	p.start()
+	p.nodeMu.Lock()
+	p.allNodes = all
+	p.valid = valid
+	p.nodeMu.Unlock()
 
-	p.done = make(chan struct{})
-	p.once.Do(func() { close(p.done) })
+	//开始启动
+	p.start(valid)
+	if err = p.aborted(ctx, done); err != nil {
+		return err
+	}
 
-	exitChan := make(chan os.Signal)
-	signal.Notify(exitChan, os.Interrupt, os.Kill, syscall.SIGTERM)
-	go func() { <-exitChan; p.Close() }()
+	if p.Len() == 0 {
+		return ErrNoValidNode
+	}
 
-	<-p.done
+	//后台任务,已经Stop时不再启动
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err = p.aborted(ctx, done); err != nil {
+		return err
+	}
+	bgCtx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	if p.refresh > 0 {
+		p.goBackground(bgCtx, p.refreshLoop)
+	}
+	if p.health > 0 {
+		p.goBackground(bgCtx, p.monitorLoop)
+	}
 
 	return nil
 }
 
-func (p *Pool) Close() error {
-	for _, n := range p.allNodes {
-		n.Stop()
+// aborted Start的各个阶段之间检查是否已经Stop或者ctx结束
+func (p *Pool) aborted(ctx context.Context, done chan struct{}) error {
+	select {
+	case <-done:
+		return ErrPoolClosed
+	default:
 	}
-	if p.done != nil {
-		p.once.Do(func() { close(p.done) })
+	return ctx.Err()
+}
+
+// goBackground 运行后台任务,Stop时取消并等待结束
+func (p *Pool) goBackground(ctx context.Context, f func(ctx context.Context)) {
+	p.bg.Add(1)
+	go func() {
+		defer p.bg.Done()
+		f(ctx)
+	}()
+}
+
+// Stop 关闭代理池,等待借出的节点归还(直到ctx结束),然后停止所有节点
+func (p *Pool) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.running {
+		p.mu.Unlock()
+		return nil
 	}
-	return nil
+	p.running = false
+	close(p.done)
+	starting := p.starting
+	p.mu.Unlock()
+
+	//等待正在进行的Start结束,Start发现已经Stop后会尽快返回
+	if starting != nil {
+		<-starting
+	}
+
+	p.mu.Lock()
+	cancel := p.cancel
+	p.cancel = nil
+	p.mu.Unlock()
+
+	//等待后台任务结束
+	if cancel != nil {
+		cancel()
+	}
+	p.bg.Wait()
+
+	var errs []error
+
+	//等待借出的节点归还
+	t := time.NewTicker(time.Millisecond * 50)
+	defer t.Stop()
+	for atomic.LoadInt32(&p.leased) > 0 {
+		select {
+		case <-ctx.Done():
+			errs = append(errs, ctx.Err())
+		case <-t.C:
+			continue
+		}
+		break
+	}
+
+	for _, n := range p.nodes() {
+		if err := n.Stop(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	//清空代理池
+	p.filter(func(n *Node) bool { return false })
+	p.portMu.Lock()
+	p.pending = nil
+	p.portMu.Unlock()
+
+	p.mu.Lock()
+	p.started = make(chan struct{})
+	p.mu.Unlock()
+
+	return errors.Join(errs...)
+}
+
+func (p *Pool) Close() error {
+	return p.Stop(context.Background())
 	//return os.RemoveAll(p.configDir)
 }
 
-func (p *Pool) subscribe() map[string]struct{} {
+// nodes 全部节点的快照
+func (p *Pool) nodes() types.List[*Node] {
+	p.nodeMu.Lock()
+	defer p.nodeMu.Unlock()
+	return p.allNodes.Copy()
+}
+
+// subscribe 获取所有节点地址,去重,返回的错误是获取失败的订阅地址
+func (p *Pool) subscribe(ctx context.Context) (map[string]struct{}, error) {
 	m := make(map[string]struct{})
+	var errs []error
 	for _, u := range p.subscribes {
-		ls, err := p.get(u)
+		ls, err := p.get(ctx, u)
 		if err != nil {
-			logs.Err(err)
+			errs = append(errs, err)
 			continue
 		}
 		for _, l := range ls {
@@ -211,11 +632,25 @@ func (p *Pool) subscribe() map[string]struct{} {
 	for _, u := range p.nodeUrls {
 		m[u] = struct{}{}
 	}
-	return m
+	for _, path := range p.configFiles {
+		ls, err := p.configFileLinks(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, l := range ls {
+			m[l] = struct{}{}
+		}
+	}
+	return m, errors.Join(errs...)
 }
 
-func (p *Pool) get(u string) ([]string, error) {
-	resp, err := http.Get(u)
+func (p *Pool) get(ctx context.Context, u string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -224,19 +659,20 @@ func (p *Pool) get(u string) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	ls := strings.Split(string(bs), "\n")
-	return ls, nil
+	return decodeSubscription(bs)
 }
 
-func (p *Pool) parse(m map[string]struct{}) {
+func (p *Pool) parse(m map[string]struct{}) types.List[*Node] {
+	ls := types.List[*Node](nil)
 	for u, _ := range m {
 		n, err := p.parseNode(u)
 		if err != nil {
 			logs.Warn(err)
 			continue
 		}
-		p.allNodes = append(p.allNodes, n)
+		ls = append(ls, n)
 	}
+	return ls
 }
 
 func (p *Pool) parseNode(u string) (*Node, error) {
@@ -244,19 +680,36 @@ func (p *Pool) parseNode(u string) (*Node, error) {
 		origin:     u,
 		listenPort: -1,
 		fail:       make(map[string]int),
+		failLimit:  p.failLimit,
+		cooldown:   p.cooldown,
 	}
-	if err := n.parse(); err != nil {
+	//配置文件的节点只能来自WithConfigFile,不解析订阅内容中的file://链接
+	var err error
+	if strings.HasPrefix(u, "file://") {
+		n.Vnexter, err = p.parseConfigFile(u)
+	} else {
+		err = n.parse()
+	}
+	if err != nil {
 		return nil, err
 	}
+	core, err := p.coreOf(n)
+	if err != nil {
+		return nil, err
+	}
+	n.core = core
 	return n, nil
 }
 
-func (p *Pool) check() {
+// check 校验节点,返回有效的节点,按延迟排序
+func (p *Pool) check(nodes types.List[*Node]) types.List[*Node] {
 	if p.nodeFunc == nil {
 		p.nodeFunc = ByPing
 	}
+	valid := types.List[*Node](nil)
+	mu := sync.Mutex{}
 	wg := sync.WaitGroup{}
-	for _, n := range p.allNodes {
+	for _, n := range nodes {
 		if n == nil {
 			//logs.Debug("n is nil")
 			continue
@@ -269,44 +722,74 @@ func (p *Pool) check() {
 				//logs.Warn(err)
 				return
 			}
-			p.valid = append(p.valid, n)
+			mu.Lock()
+			valid = append(valid, n)
+			mu.Unlock()
 		}(n)
 	}
 	wg.Wait()
-	p.valid.Sort(func(a, b *Node) bool {
+	return valid.Sort(func(a, b *Node) bool {
 		return a.checkSpend < b.checkSpend
 	})
 }
 
-func (p *Pool) start() {
-	if len(p.valid) == 0 {
+// start 启动节点,通过校验的节点放入代理池
+func (p *Pool) start(nodes types.List[*Node]) {
+	if len(nodes) == 0 {
 		return
 	}
 	os.MkdirAll(p.configDir, os.ModePerm)
-	port := p.startPort
+	ports := p.allocPorts(nodes)
+	if len(ports) < len(nodes) {
+		//端口不够的节点不启动,健康检查时会重新尝试
+		nodes = nodes[:len(ports)]
+	}
+	hostErr := false
+	if p.host != nil {
+		//单进程模式,一次启动所有xray内核的节点
+		var hosted []*Node
+		var hostedPorts []int
+		for i, n := range nodes {
+			if p.hosted(n) {
+				hosted = append(hosted, n)
+				hostedPorts = append(hostedPorts, ports[i])
+			}
+		}
+		if len(hosted) > 0 {
+			if err := p.startHosted(hosted, hostedPorts); err != nil {
+				logs.Err(err)
+				hostErr = true
+			}
+		}
+	}
 	wg := sync.WaitGroup{}
-	wg.Add(len(p.valid))
-	for _, n := range p.valid {
+	wg.Add(len(nodes))
+	for i, n := range nodes {
 		go func(n *Node, port int) {
 			defer wg.Done()
-			if err := n.Start(port, p.protocol, p.configDir, p.cmd); err != nil {
-				//logs.Warn(err)
+			if p.hosted(n) && hostErr {
 				return
 			}
+			if !p.hosted(n) {
+				if err := p.startNode(n, port); err != nil {
+					//logs.Warn(err)
+					return
+				}
+			}
 			if p.proxyCheck != nil {
-				if _, err := p.proxyCheck(n); err != nil {
+				spend, err := p.proxyCheck(n)
+				if err != nil {
 					//logs.Warn(err)
 					n.Stop()
 					return
 				}
+				n.setLatency(spend)
 			}
 			logs.Info(n.Proxy(), "->", n.Origin())
-			p.pool <- n
-		}(n, port)
-		port++
+			p.put(n)
+		}(n, ports[i])
 	}
 	wg.Wait()
-	close(p.started)
 }
 
 type Node struct {
@@ -314,13 +797,27 @@ type Node struct {
 
 	origin         string         // 原始 vmess:// 链接
 	listenProtocol string         // 本地 V2Ray 实例协议 http socks
-	listenPort     int            // 本地 V2Ray 实例端口
+	listenPort     int32          // 本地 V2Ray 实例端口,原子读写
 	process        *exec.Cmd      // 本地 V2Ray 进程
+	processMu      sync.Mutex     // process的锁
+	core           Core           // 运行节点的内核,默认xray
+	host           *hostProcess   // 单进程模式下所在的进程
 	fail           map[string]int // 请求地址对应的失败次数
 	failLimit      int            // 失败次数限制
+	fails          int            // 连续失败次数
+	cooldown       time.Duration  // 熔断冷却时间
+	breaker        int            // 熔断状态
+	quarantined    bool           // 是否隔离中
+	breakerMu      sync.Mutex     // 熔断锁
 	checkSpend     time.Duration  // 检查节点耗时
 
-	running uint32
+	running   uint32
+	removed   uint32 // 已经从订阅中移除,等待归还后停止
+	unhealthy uint32 // 健康检查失败,已移出代理池
+	admitted  uint32 // 是否属于代理池(空闲或借出中)
+	leases    int32  // 借出次数
+	lastUsed  int64  // 最近一次借出的时间
+	latency   int64  // 最近一次代理校验的耗时
 }
 
 func (n *Node) String() string {
@@ -335,14 +832,33 @@ func (n *Node) Origin() string {
 	return n.origin
 }
 
+// ListenPort 本地监听端口,未启动时为-1
+func (n *Node) ListenPort() int {
+	return int(atomic.LoadInt32(&n.listenPort))
+}
+
+func (n *Node) setListenPort(port int) {
+	atomic.StoreInt32(&n.listenPort, int32(port))
+}
+
+// Removed 节点是否已经从订阅中移除
+func (n *Node) Removed() bool {
+	return atomic.LoadUint32(&n.removed) == 1
+}
+
+// remove 标记节点已经从订阅中移除
+func (n *Node) remove() {
+	atomic.StoreUint32(&n.removed, 1)
+}
+
 func (n *Node) Proxy() string {
 	switch n.listenProtocol {
 	case Socks:
-		return fmt.Sprintf("socks5://127.0.0.1:%d", n.listenPort)
+		return fmt.Sprintf("socks5://127.0.0.1:%d", n.ListenPort())
 	case Http, Mixed:
-		return fmt.Sprintf("http://127.0.0.1:%d", n.listenPort)
+		return fmt.Sprintf("http://127.0.0.1:%d", n.ListenPort())
 	}
-	return fmt.Sprintf("socks5://127.0.0.1:%d", n.listenPort)
+	return fmt.Sprintf("socks5://127.0.0.1:%d", n.ListenPort())
 }
 
 func (n *Node) parse() (err error) {
@@ -353,8 +869,20 @@ func (n *Node) parse() (err error) {
 		n.Vnexter, err = ParseVless(n.origin)
 	case strings.HasPrefix(n.origin, Trojan):
 		n.Vnexter, err = ParseTrojan(n.origin)
+	case strings.HasPrefix(n.origin, "ss://"):
+		n.Vnexter, err = ParseShadowsocks(n.origin)
+	case strings.HasPrefix(n.origin, "wireguard://"), strings.HasPrefix(n.origin, "wg://"):
+		n.Vnexter, err = ParseWireguard(n.origin)
+	case strings.HasPrefix(n.origin, "socks5://"), strings.HasPrefix(n.origin, "socks://"):
+		n.Vnexter, err = ParseSocks(n.origin)
+	case strings.HasPrefix(n.origin, "http://"), strings.HasPrefix(n.origin, "https://"):
+		n.Vnexter, err = ParseHttp(n.origin)
+	case strings.HasPrefix(n.origin, "hysteria2://"), strings.HasPrefix(n.origin, "hy2://"):
+		n.Vnexter, err = ParseHysteria2(n.origin)
+	case strings.HasPrefix(n.origin, "tuic://"):
+		n.Vnexter, err = ParseTuic(n.origin)
 	default:
-		err = fmt.Errorf("invalid protocol, must be [vmess|vless|trojan]: %s", n.origin)
+		err = fmt.Errorf("invalid protocol, must be [vmess|vless|trojan|ss|wireguard|socks5|http|hysteria2|tuic]: %s", n.origin)
 	}
 	return
 }
@@ -366,84 +894,172 @@ func (n *Node) check(f CheckFunc) error {
 	return err
 }
 
-//func (n *Node) Fail(url string) {
-//	n.fail[url] = n.fail[url] + 1
-//	if n.failLimit > 0 && len(n.fail) > n.failLimit {
-//		//todo
-//	}
-//	n.Stop()
-//}
-
+// Start 使用节点的内核启动节点,cmd为空时使用内核的默认命令
 func (n *Node) Start(port int, protocol, configDir string, cmd []string) error {
 
 	n.Stop()
 
-	n.listenPort = port
+	n.setListenPort(port)
 	n.listenProtocol = protocol
 
-	// 生成临时 V2Ray 配置
-	config := Config{
-		Log: DefaultLog,
-		Inbounds: []Bound{
-			{
-				Port:     port,
-				Listen:   "0.0.0.0",
-				Protocol: protocol,
-				Settings: &Settings{
-					Udp: true,
-				},
-			},
-		},
-		Outbounds: []Bound{
-			{
-				Protocol:       n.Protocol(),
-				Settings:       n.Settings(),
-				StreamSettings: n.StreamSettings(),
-			},
-		},
+	core := n.Core()
+	if len(cmd) == 0 {
+		cmd = core.Cmd()
 	}
 
-	// 保存临时配置
-	file := fmt.Sprintf(configDir+"%d.json", port)
-	if err := os.WriteFile(file, config.Bytes(), 0644); err != nil {
+	// 生成临时配置
+	bs, err := core.Config(n)
+	if err != nil {
 		return err
 	}
 
-	// 启动 V2Ray/Xray
-	name, args := cmd[0], append(cmd[1:], file)
-	c := exec.Command(name, args...)
+	// 保存临时配置
+	file := fmt.Sprintf(configDir+"%d%s", port, core.Ext())
+	if err := os.WriteFile(file, bs, 0644); err != nil {
+		return err
+	}
 
-	stdout, err := c.StdoutPipe()
+	// 启动内核,在入站端口上握手成功才算启动成功
+	c, err := runProcess(cmd, file, core.Error, func() error {
+		return probeInbound(protocol, port, DefaultTimeout)
+	}, func(c *exec.Cmd) {
+		n.processMu.Lock()
+		defer n.processMu.Unlock()
+		if n.process == nil || n.process == c {
+			atomic.StoreUint32(&n.running, 0)
+		}
+	})
 	if err != nil {
 		return err
 	}
-	defer stdout.Close()
+	n.processMu.Lock()
+	defer n.processMu.Unlock()
+	atomic.StoreUint32(&n.running, 1)
+	n.process = c
+	return nil
+}
+
+// Core 运行节点的内核
+func (n *Node) Core() Core {
+	if n.core == nil {
+		return defaultCore
+	}
+	return n.core
+}
+
+// inbound 节点的本地入站
+func (n *Node) inbound() Bound {
+	return Bound{
+		Port:     n.ListenPort(),
+		Listen:   "0.0.0.0",
+		Protocol: n.listenProtocol,
+		Settings: &Settings{
+			Udp: true,
+		},
+	}
+}
+
+// outbound 节点的出站
+func (n *Node) outbound() Bound {
+	return Bound{
+		Protocol:       n.Protocol(),
+		Settings:       n.Settings(),
+		StreamSettings: n.StreamSettings(),
+	}
+}
+
+// runProcess 使用配置文件启动进程,通过probe探测入站端口等待启动成功,进程退出时执行onExit
+// stdout和stderr合并在一起交给check识别启动失败的原因,进程提前退出时返回的错误包含stderr的内容
+func runProcess(cmd []string, file string, check func(line string) error, probe func() error, onExit func(c *exec.Cmd)) (*exec.Cmd, error) {
+	name, args := cmd[0], append(cmd[1:len(cmd):len(cmd)], file)
+	c := exec.Command(name, args...)
 
+	r, w := io.Pipe()
+	stderr := &tailBuffer{}
+	c.Stdout, c.Stderr = w, io.MultiWriter(w, stderr)
+	if err := c.Start(); err != nil {
+		return nil, err
+	}
+	var waitErr error
 	go func() {
-		err := c.Run()
-		_ = err
-		//logs.PrintErr(err)
-		atomic.StoreUint32(&n.running, 0)
+		waitErr = c.Wait()
+		w.Close()
 	}()
 
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		line := scanner.Text()
-		//等待成功启动
-		switch {
-		case strings.Contains(line, "[Info] infra/conf/serial: Reading config:"):
-			atomic.StoreUint32(&n.running, 1)
-			n.process = c
-			return nil
-		case strings.Contains(line, "bind: Only one usage of each socket address"):
-			return ErrInvalidPort
+	failed := make(chan error, 1)
+	exited := make(chan struct{})
+	go func() {
+		defer onExit(c)
+		defer close(exited)
+		//继续读取输出直到进程退出,避免进程写入阻塞
+		defer io.Copy(io.Discard, r)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			if err := check(scanner.Text()); err != nil {
+				c.Process.Kill()
+				failed <- err
+				return
+			}
 		}
+	}()
+
+	timeout := time.NewTimer(DefaultStartWait)
+	defer timeout.Stop()
+	tick := time.NewTicker(time.Millisecond * 100)
+	defer tick.Stop()
+	for {
+		select {
+		case err := <-failed:
+			return nil, err
+		case <-exited:
+			select {
+			case err := <-failed:
+				return nil, err
+			default:
+				return nil, fmt.Errorf("process exited: %v: %s", waitErr, stderr)
+			}
+		case <-timeout.C:
+			c.Process.Kill()
+			if s := stderr.String(); s != "" {
+				return nil, fmt.Errorf("%w: %s", errNotReady, s)
+			}
+			return nil, errNotReady
+		case <-tick.C:
+			if probe() == nil {
+				return c, nil
+			}
+		}
+	}
+}
+
+// tailBuffer 保留最后一部分输出,用于进程退出时的错误信息
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - 4096; over > 0 {
+		b.buf = b.buf[over:]
 	}
+	return len(p), nil
+}
 
-	return nil
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.TrimSpace(string(b.buf))
 }
 
 func (n *Node) Stop() error {
+	if n.host != nil {
+		return n.host.remove(n)
+	}
+	n.processMu.Lock()
+	defer n.processMu.Unlock()
 	if n.process == nil || n.process.Process == nil {
 		return nil
 	}
@@ -451,7 +1067,8 @@ func (n *Node) Stop() error {
 	if err != nil {
 		return err
 	}
-	n.listenPort = -1
+	atomic.StoreUint32(&n.running, 0)
+	n.setListenPort(-1)
 	n.checkSpend = -1
 	n.process = nil
 	return nil
@@ -0,0 +1,157 @@
+package xray_pool
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testPool 代理池和若干运行中的节点,节点已经放入代理池
+func testPool(t *testing.T, num int, op ...Option) (*Pool, []*Node) {
+	p := New(op...)
+	nodes := make([]*Node, num)
+	for i := range nodes {
+		nodes[i] = &Node{origin: string(rune('a' + i)), running: 1, fail: make(map[string]int)}
+		p.allNodes = append(p.allNodes, nodes[i])
+		p.valid = append(p.valid, nodes[i])
+		p.put(nodes[i])
+	}
+	return p, nodes
+}
+
+func TestPutDuplicate(t *testing.T) {
+	for _, shared := range []int{0, 2} {
+		p, nodes := testPool(t, 1, WithShared(shared))
+		p.put(nodes[0])
+		if p.Len() != 1 {
+			t.Fatalf("shared %d: node added twice, len: %d", shared, p.Len())
+		}
+	}
+}
+
+func TestSharedLeases(t *testing.T) {
+	p, nodes := testPool(t, 1, WithShared(2))
+	for i := 0; i < 2; i++ {
+		if n, err := p.TryGet(); err != nil || n != nodes[0] {
+			t.Fatalf("lease %d: %v", i, err)
+		}
+	}
+	if _, err := p.TryGet(); err != ErrPoolEmpty {
+		t.Fatalf("want ErrPoolEmpty, got: %v", err)
+	}
+	p.Put(nodes[0])
+	if _, err := p.TryGet(); err != nil {
+		t.Fatalf("lease after put: %v", err)
+	}
+	if p.Len() != 1 || p.Leased() != 2 || nodes[0].Leases() != 2 {
+		t.Fatalf("len: %d, leased: %d, node leases: %d", p.Len(), p.Leased(), nodes[0].Leases())
+	}
+}
+
+// 借出的节点停止后归还,等待中的调用者需要被唤醒并获取其他空闲节点
+func TestMaxLeasesWake(t *testing.T) {
+	for _, shared := range []int{0, 2} {
+		p, _ := testPool(t, 2, WithMaxLeases(1), WithShared(shared))
+		n, err := p.TryGet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			atomic.StoreUint32(&n.running, 0)
+			p.Put(n)
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		m, err := p.GetContext(ctx)
+		cancel()
+		if err != nil || m == n {
+			t.Fatalf("shared %d: waiter not woken: %v, len: %d, leased: %d", shared, err, p.Len(), p.Leased())
+		}
+	}
+}
+
+func TestGetContext(t *testing.T) {
+	p, _ := testPool(t, 0)
+	if _, err := p.TryGet(); err != ErrPoolEmpty {
+		t.Fatalf("want ErrPoolEmpty, got: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := p.GetContext(ctx); err != ErrDeadline {
+		t.Fatalf("want ErrDeadline, got: %v", err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.GetContext(ctx); err != context.Canceled {
+		t.Fatalf("want context.Canceled, got: %v", err)
+	}
+
+	//等待中放入节点
+	n := &Node{origin: "a", running: 1}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		p.put(n)
+	}()
+	if m, err := p.GetContext(context.Background()); err != nil || m != n {
+		t.Fatalf("want put node, got: %v", err)
+	}
+
+	//等待中关闭
+	p.mu.Lock()
+	p.done = make(chan struct{})
+	done := p.done
+	p.mu.Unlock()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(done)
+	}()
+	if _, err := p.GetContext(context.Background()); err != ErrPoolClosed {
+		t.Fatalf("want ErrPoolClosed, got: %v", err)
+	}
+	if _, err := p.TryGet(); err != ErrPoolClosed {
+		t.Fatalf("want ErrPoolClosed, got: %v", err)
+	}
+}
+
+func TestRunProcess(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip(err)
+	}
+	script := func(s string) []string { return []string{"sh", "-c", s} }
+	noExit := func(c *exec.Cmd) {}
+
+	//提前退出时返回错误输出
+	_, err := runProcess(script("echo config error >&2; exit 1"), "config.json", defaultCore.Error, func() error { return errNotReady }, noExit)
+	if err == nil || !strings.Contains(err.Error(), "config error") {
+		t.Fatalf("want exit error with stderr, got: %v", err)
+	}
+
+	//端口被占用
+	_, err = runProcess(script("echo 'listen tcp :50001: bind: address already in use'; exec sleep 5"), "config.json", defaultCore.Error, func() error { return errNotReady }, noExit)
+	if !errors.Is(err, ErrInvalidPort) {
+		t.Fatalf("want ErrInvalidPort, got: %v", err)
+	}
+
+	//入站握手成功后返回,和日志内容无关
+	probes := 0
+	exited := make(chan struct{})
+	c, err := runProcess(script("exec sleep 5"), "config.json", defaultCore.Error, func() error {
+		if probes++; probes < 3 {
+			return errNotReady
+		}
+		return nil
+	}, func(c *exec.Cmd) { close(exited) })
+	if err != nil || probes != 3 {
+		t.Fatalf("want ready after 3 probes, got: %d %v", probes, err)
+	}
+	c.Process.Kill()
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("onExit not called")
+	}
+}
@@ -0,0 +1,288 @@
+package xray_pool
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/injoyai/logs"
+)
+
+const (
+	maxPortRetry = 3                   // 端口被占用时换端口重试的次数
+	portsFile    = "ports.json"        // 固定端口模式下节点指纹和端口的对应关系
+	portExpire   = 30 * 24 * time.Hour // 节点超过这个时间没有出现时删除端口记录
+	portSeenStep = 24 * time.Hour      // 更新节点出现时间的间隔,避免频繁写文件
+)
+
+// portRecords 节点指纹对应的端口记录
+type portRecords map[string]portRecord
+
+// portRecord 固定端口模式下节点的端口记录
+type portRecord struct {
+	Port int       `json:"port"`
+	Seen time.Time `json:"seen"` // 最近一次出现的时间
+}
+
+// WithPortRange 设置节点监听的端口范围[start,end],超过65535的部分不会使用
+func WithPortRange(start, end int) Option {
+	return func(p *Pool) {
+		p.startPort = start
+		p.endPort = end
+	}
+}
+
+// WithStablePort 固定端口模式,节点的端口由节点指纹计算得到,并保存在配置目录的ports.json中
+// 同一个服务器在多次Run和订阅刷新之间保持相同的本地端口,超过30天没有出现的节点删除记录
+func WithStablePort() Option {
+	return func(p *Pool) {
+		p.stable = true
+	}
+}
+
+// allocPorts 给节点分配端口,端口不够时只分配前面的节点
+func (p *Pool) allocPorts(nodes []*Node) []int {
+	if !p.stable {
+		return p.freePorts(len(nodes))
+	}
+
+	p.portMu.Lock()
+	defer p.portMu.Unlock()
+
+	p.loadPorts()
+	used := p.usedPorts()
+	changed := p.expirePorts(used)
+	reserved := make(map[int]bool, len(p.portMap))
+	for _, r := range p.portMap {
+		reserved[r.Port] = true
+	}
+	now := time.Now()
+	ports := make([]int, 0, len(nodes))
+	for _, n := range nodes {
+		fp := n.fingerprint()
+		r, ok := p.portMap[fp]
+		if ok && now.Sub(r.Seen) > portSeenStep {
+			p.portMap[fp] = portRecord{Port: r.Port, Seen: now}
+			changed = true
+		}
+		port := r.Port
+		if !ok || used[port] || !portFree(port) {
+			port = p.derivePort(fp, used, reserved)
+			if port == 0 {
+				logs.Warnf("可用端口不足, 需要: %d, 可用: %d\n", len(nodes), len(ports))
+				break
+			}
+			//已经有记录时(端口暂时被占用,或者相同的服务器存在多个节点),这次使用临时端口,不覆盖记录
+			if !ok {
+				p.portMap[fp] = portRecord{Port: port, Seen: now}
+				reserved[port] = true
+				changed = true
+			}
+		}
+		used[port] = true
+		p.reservePort(port)
+		ports = append(ports, port)
+	}
+	if changed {
+		p.savePorts()
+	}
+	return ports
+}
+
+// derivePort 从节点指纹计算端口,端口不可用时依次往后找
+func (p *Pool) derivePort(fp string, used, reserved map[int]bool) int {
+	start, end := p.portRange()
+	size := uint32(end - start + 1)
+	h := fnv.New32a()
+	h.Write([]byte(fp))
+	base := h.Sum32() % size
+	for i := uint32(0); i < size; i++ {
+		port := start + int((base+i)%size)
+		if !used[port] && !reserved[port] && portFree(port) {
+			return port
+		}
+	}
+	return 0
+}
+
+// expirePorts 删除长时间没有出现的节点的端口记录,端口正在使用的记录视为出现,返回是否有变化
+func (p *Pool) expirePorts(used map[int]bool) bool {
+	changed := false
+	now := time.Now()
+	for fp, r := range p.portMap {
+		switch {
+		case used[r.Port]:
+			if now.Sub(r.Seen) > portSeenStep {
+				p.portMap[fp] = portRecord{Port: r.Port, Seen: now}
+				changed = true
+			}
+		case now.Sub(r.Seen) > portExpire:
+			delete(p.portMap, fp)
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (p *Pool) loadPorts() {
+	if p.portMap != nil {
+		return
+	}
+	p.portMap = make(portRecords)
+	bs, err := os.ReadFile(p.configDir + portsFile)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(bs, &p.portMap); err != nil {
+		logs.Warn("读取端口记录失败:", err)
+	}
+}
+
+func (p *Pool) savePorts() {
+	bs, err := json.MarshalIndent(p.portMap, "", "  ")
+	if err == nil {
+		os.MkdirAll(p.configDir, os.ModePerm)
+		err = os.WriteFile(p.configDir+portsFile, bs, 0644)
+	}
+	if err != nil {
+		logs.Warn("保存端口记录失败:", err)
+	}
+}
+
+// fingerprint 节点指纹,只和服务器的连接参数有关,和备注及链接格式无关
+func (n *Node) fingerprint() string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s|%s|%d|", n.Protocol(), n.Hostname(), n.Port())
+	if s := n.Settings(); s != nil {
+		json.NewEncoder(h).Encode(s)
+		json.NewEncoder(h).Encode(n.StreamSettings())
+	} else {
+		//没有xray配置的节点使用去掉备注的链接
+		link, _, _ := strings.Cut(n.origin, "#")
+		h.Write([]byte(link))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// usedPorts 节点正在使用和已经分配还没有启动完成的端口,需要持有portMu
+func (p *Pool) usedPorts() map[int]bool {
+	used := make(map[int]bool, len(p.pending))
+	for port := range p.pending {
+		used[port] = true
+	}
+	for _, n := range p.nodes() {
+		if port := n.ListenPort(); port > 0 {
+			used[port] = true
+		}
+	}
+	return used
+}
+
+// portRange 端口范围,结束端口不超过65535
+func (p *Pool) portRange() (int, int) {
+	start, end := max(p.startPort, 1), min(p.endPort, DefaultEndPort)
+	if end <= 0 {
+		end = DefaultEndPort
+	}
+	return start, max(start, end)
+}
+
+// freePorts 在端口范围内分配num个未被节点占用并且能监听的端口
+// 固定端口模式下跳过已经记录给其他节点的端口,可用端口不够时返回的数量少于num
+func (p *Pool) freePorts(num int) []int {
+	p.portMu.Lock()
+	defer p.portMu.Unlock()
+
+	used := p.usedPorts()
+	if p.stable {
+		p.loadPorts()
+		for _, r := range p.portMap {
+			used[r.Port] = true
+		}
+	}
+	start, end := p.portRange()
+	ports := make([]int, 0, num)
+	for port := start; port <= end && len(ports) < num; port++ {
+		if !used[port] && portFree(port) {
+			p.reservePort(port)
+			ports = append(ports, port)
+		}
+	}
+	if len(ports) < num {
+		logs.Warnf("可用端口不足, 需要: %d, 可用: %d\n", num, len(ports))
+	}
+	return ports
+}
+
+// reservePort 预留分配出去的端口,避免同时启动的节点分配到相同的端口,需要持有portMu
+func (p *Pool) reservePort(port int) {
+	if p.pending == nil {
+		p.pending = make(map[int]bool)
+	}
+	p.pending[port] = true
+}
+
+// releasePorts 节点启动完成或者失败后释放预留的端口,启动成功的端口由节点占用
+func (p *Pool) releasePorts(ports ...int) {
+	p.portMu.Lock()
+	defer p.portMu.Unlock()
+	for _, port := range ports {
+		delete(p.pending, port)
+	}
+}
+
+// portFree 端口是否可以监听
+func portFree(port int) bool {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return false
+	}
+	ln.Close()
+	return true
+}
+
+// startNode 启动节点,端口被占用时换一个端口重试,结束后释放预留的端口
+// 重试使用的是临时端口,固定端口模式下不修改记录
+func (p *Pool) startNode(n *Node, port int) error {
+	tried := []int{port}
+	defer func() { p.releasePorts(tried...) }()
+	for i := 0; ; i++ {
+		err := n.Start(port, p.protocol, p.configDir, p.cmdOf(n))
+		if !errors.Is(err, ErrInvalidPort) || i >= maxPortRetry {
+			return err
+		}
+		ports := p.freePorts(1)
+		if len(ports) == 0 {
+			return ErrNoFreePort
+		}
+		logs.Warnf("端口[%d]被占用, 使用端口[%d]重试\n", port, ports[0])
+		port = ports[0]
+		tried = append(tried, port)
+	}
+}
+
+// startHosted 单进程模式下启动节点,端口被占用时给这些节点重新分配端口重试,结束后释放预留的端口
+func (p *Pool) startHosted(nodes []*Node, ports []int) error {
+	tried := slices.Clone(ports)
+	defer func() { p.releasePorts(tried...) }()
+	for i := 0; ; i++ {
+		err := p.host.start(nodes, ports, p.protocol, p.configDir, p.cmdOf(nodes[0]))
+		if !errors.Is(err, ErrInvalidPort) || i >= maxPortRetry {
+			return err
+		}
+		ports = p.freePorts(len(nodes))
+		tried = append(tried, ports...)
+		if len(ports) < len(nodes) {
+			return ErrNoFreePort
+		}
+		logs.Warn("端口被占用, 重新分配端口重试")
+	}
+}
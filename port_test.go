@@ -0,0 +1,92 @@
+package xray_pool
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestExpirePorts(t *testing.T) {
+	p := New(WithConfigDir(t.TempDir()+"/"), WithStablePort())
+	p.loadPorts()
+	expired := time.Now().Add(-portExpire - time.Hour)
+	p.portMap["old"] = portRecord{Port: 50001, Seen: expired}
+	p.portMap["used"] = portRecord{Port: 50002, Seen: expired}
+	p.portMap["new"] = portRecord{Port: 50003, Seen: time.Now()}
+	if !p.expirePorts(map[int]bool{50002: true}) {
+		t.Fatal("want changed")
+	}
+	if _, ok := p.portMap["old"]; ok {
+		t.Fatal("expired record not removed")
+	}
+	if r := p.portMap["used"]; time.Since(r.Seen) > time.Minute {
+		t.Fatalf("used record not refreshed: %v", r.Seen)
+	}
+	if _, ok := p.portMap["new"]; !ok {
+		t.Fatal("fresh record removed")
+	}
+}
+
+// 记录的端口暂时被占用时使用临时端口,不修改记录
+func TestStablePortBusy(t *testing.T) {
+	dir := t.TempDir() + "/"
+	p := New(WithConfigDir(dir), WithStablePort(), WithPortRange(53000, 53100))
+	n, err := p.parseNode("trojan://password@t.example.com:443#trojan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alloc := func() int {
+		ports := p.allocPorts([]*Node{n})
+		if len(ports) != 1 {
+			t.Fatal("no port")
+		}
+		p.releasePorts(ports...)
+		return ports[0]
+	}
+	port := alloc()
+
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmp := alloc(); tmp == port {
+		t.Fatal("busy port allocated")
+	}
+	ln.Close()
+	if got := alloc(); got != port {
+		t.Fatalf("record changed: %d != %d", got, port)
+	}
+
+	//重新加载记录
+	p = New(WithConfigDir(dir), WithStablePort(), WithPortRange(53000, 53100))
+	if got := alloc(); got != port {
+		t.Fatalf("record not saved: %d != %d", got, port)
+	}
+}
+
+// 分配端口时读取节点端口,和节点启动停止同时进行
+func TestUsedPortsRace(t *testing.T) {
+	p := New(WithConfigDir(t.TempDir()+"/"), WithCmd([]string{"sh", "-c", "exit 1"}))
+	n, err := p.parseNode("trojan://password@t.example.com:443#trojan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.allNodes = append(p.allNodes, n)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			p.startNode(n, 52100+i)
+			n.Stop()
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			p.freePorts(1)
+		}
+	}
+}
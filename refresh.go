@@ -0,0 +1,129 @@
+package xray_pool
+
+import (
+	"context"
+	"time"
+
+	"github.com/injoyai/base/types"
+	"github.com/injoyai/logs"
+)
+
+// WithRefresh 设置订阅刷新间隔,定时重新获取订阅并更新节点,小于等于0不刷新
+func WithRefresh(interval time.Duration) Option {
+	return func(p *Pool) {
+		p.refresh = interval
+	}
+}
+
+func (p *Pool) refreshLoop(ctx context.Context) {
+	t := time.NewTicker(p.refresh)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := p.Refresh(ctx); err != nil {
+				logs.Err(err)
+			}
+		}
+	}
+}
+
+// Refresh 重新获取订阅,按原始链接和现有节点对比
+// 新增的节点校验后启动,消失的节点移出代理池并停止(借出的节点归还后停止),未变化的节点保持运行
+// 上次校验失败的节点会重新校验,通过后启动
+// 有订阅获取失败时,只新增节点,不移除节点,避免网络波动清空代理池
+func (p *Pool) Refresh(ctx context.Context) error {
+	select {
+	case <-p.closed():
+		return ErrPoolClosed
+	default:
+	}
+
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+
+	m, subErr := p.subscribe(ctx)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	//对比节点,上次校验失败的节点重新校验
+	added := make(map[string]struct{})
+	removed := make(map[*Node]struct{})
+	var retry types.List[*Node]
+	retried := make(map[*Node]bool)
+	p.nodeMu.Lock()
+	exist := make(map[string]struct{}, len(p.allNodes))
+	valid := make(map[*Node]bool, len(p.valid))
+	for _, n := range p.valid {
+		valid[n] = true
+	}
+	for _, n := range p.allNodes {
+		exist[n.origin] = struct{}{}
+		if _, ok := m[n.origin]; !ok && subErr == nil {
+			removed[n] = struct{}{}
+		} else if !valid[n] {
+			retry = append(retry, n)
+			retried[n] = true
+		}
+	}
+	p.nodeMu.Unlock()
+	for u := range m {
+		if _, ok := exist[u]; !ok {
+			added[u] = struct{}{}
+		}
+	}
+
+	//移除消失的节点
+	if len(removed) > 0 {
+		p.nodeMu.Lock()
+		p.allNodes = p.allNodes.Where(func(i int, n *Node) bool {
+			_, ok := removed[n]
+			return !ok
+		})
+		p.valid = p.valid.Where(func(i int, n *Node) bool {
+			_, ok := removed[n]
+			return !ok
+		})
+		p.nodeMu.Unlock()
+		for n := range removed {
+			n.remove()
+		}
+		p.filter(func(n *Node) bool { return !n.Removed() })
+		//不在代理池中的节点(例如健康检查失败)也要停止,借出中的节点归还时停止
+		for n := range removed {
+			if n.Leases() == 0 {
+				n.Stop()
+			}
+		}
+	}
+
+	//启动新增的节点和重新校验通过的节点
+	recovered := 0
+	if len(added) > 0 || len(retry) > 0 {
+		all := p.parse(added)
+		checked := p.check(append(all.Copy(), retry...))
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, n := range checked {
+			if retried[n] {
+				recovered++
+			}
+		}
+		p.nodeMu.Lock()
+		p.allNodes = append(p.allNodes, all...)
+		p.nodeMu.Unlock()
+		//启动完成后再加入有效节点,避免健康检查同时启动同一个节点
+		p.start(checked)
+		p.nodeMu.Lock()
+		p.valid = append(p.valid, checked...)
+		p.nodeMu.Unlock()
+	}
+
+	logs.Infof("刷新订阅, 新增: %d, 移除: %d, 恢复: %d\n", len(added), len(removed), recovered)
+
+	return subErr
+}
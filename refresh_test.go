@@ -0,0 +1,48 @@
+package xray_pool
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+)
+
+func TestRefreshStopsRemoved(t *testing.T) {
+	//模拟运行中的节点进程
+	node := func(origin string) *Node {
+		c := exec.Command("sleep", "30")
+		if err := c.Start(); err != nil {
+			t.Skip(err)
+		}
+		t.Cleanup(func() { c.Process.Kill(); c.Wait() })
+		return &Node{origin: origin, running: 1, process: c}
+	}
+	keep, idle, unhealthy, leased := node("keep"), node("idle"), node("unhealthy"), node("leased")
+	unhealthy.setUnhealthy(true)
+
+	p := New(WithNode("keep"))
+	p.allNodes = []*Node{keep, idle, unhealthy, leased}
+	p.valid = []*Node{keep, idle, unhealthy, leased}
+	p.put(leased)
+	if p.take("") != leased {
+		t.Fatal("want leased node")
+	}
+	p.put(keep)
+	p.put(idle)
+
+	if err := p.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.nodes()) != 1 || keep.Closed() {
+		t.Fatalf("kept node changed, nodes: %d", len(p.nodes()))
+	}
+	if !idle.Closed() || !unhealthy.Closed() {
+		t.Fatal("removed node still running")
+	}
+	if leased.Closed() {
+		t.Fatal("leased node stopped before returned")
+	}
+	p.Put(leased)
+	if !leased.Closed() || p.Len() != 1 {
+		t.Fatalf("returned node not stopped, len: %d", p.Len())
+	}
+}
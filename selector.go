@@ -0,0 +1,122 @@
+package xray_pool
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// Selector 节点选择策略,从空闲的节点中选择一个,返回下标,返回-1表示不选择
+// key为GetByKey传入的值,其他方式获取时为空
+type Selector interface {
+	Select(nodes []*Node, key string) int
+}
+
+// SelectorFunc 函数形式的选择策略
+type SelectorFunc func(nodes []*Node, key string) int
+
+func (f SelectorFunc) Select(nodes []*Node, key string) int { return f(nodes, key) }
+
+// WithSelector 设置节点选择策略,默认RoundRobin
+func WithSelector(s Selector) Option {
+	return func(p *Pool) {
+		if s != nil {
+			p.selector = s
+		}
+	}
+}
+
+// RoundRobin 轮询
+func RoundRobin() Selector {
+	var count uint64
+	return SelectorFunc(func(nodes []*Node, key string) int {
+		return int((atomic.AddUint64(&count, 1) - 1) % uint64(len(nodes)))
+	})
+}
+
+// LowestLatency 延迟最低
+func LowestLatency() Selector {
+	return SelectorFunc(func(nodes []*Node, key string) int {
+		idx := 0
+		for i, n := range nodes {
+			if n.spend() < nodes[idx].spend() {
+				idx = i
+			}
+		}
+		return idx
+	})
+}
+
+// WeightedRandom 加权随机,权重和延迟成反比
+func WeightedRandom() Selector {
+	return SelectorFunc(func(nodes []*Node, key string) int {
+		weights := make([]float64, len(nodes))
+		total := 0.0
+		for i, n := range nodes {
+			weights[i] = 1 / (n.spend().Seconds() + 0.001)
+			total += weights[i]
+		}
+		r := rand.Float64() * total
+		for i, w := range weights {
+			if r < w {
+				return i
+			}
+			r -= w
+		}
+		return len(nodes) - 1
+	})
+}
+
+// LeastRecentlyUsed 最久未使用
+func LeastRecentlyUsed() Selector {
+	return SelectorFunc(func(nodes []*Node, key string) int {
+		idx := 0
+		for i, n := range nodes {
+			if atomic.LoadInt64(&n.lastUsed) < atomic.LoadInt64(&nodes[idx].lastUsed) {
+				idx = i
+			}
+		}
+		return idx
+	})
+}
+
+// ConsistentHash 一致性哈希(最高随机权重),相同的key在节点不变时选择相同的节点
+// 节点增减只影响分配到该节点的key,key为空时退化为轮询
+// GetByKey时在代理池的全部可用节点中选择,目标节点被借出时等待归还,不会换成其他节点
+// 独占模式下同一个key同时只能有一个使用者,需要并发使用时配合WithShared
+func ConsistentHash() Selector {
+	return &consistentHash{rr: RoundRobin()}
+}
+
+type consistentHash struct {
+	rr Selector
+}
+
+func (c *consistentHash) Select(nodes []*Node, key string) int {
+	if key == "" {
+		return c.rr.Select(nodes, key)
+	}
+	idx := 0
+	max := uint64(0)
+	for i, n := range nodes {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte(n.origin))
+		if sum := h.Sum64(); i == 0 || sum > max {
+			idx, max = i, sum
+		}
+	}
+	return idx
+}
+
+// spend 节点延迟,优先使用最近一次代理校验的耗时
+func (n *Node) spend() time.Duration {
+	if l := n.Latency(); l > 0 {
+		return l
+	}
+	if n.checkSpend > 0 {
+		return n.checkSpend
+	}
+	return DefaultTimeout
+}
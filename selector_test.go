@@ -0,0 +1,80 @@
+package xray_pool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSelector(t *testing.T) {
+	nodes := []*Node{{origin: "a"}, {origin: "b"}, {origin: "c"}}
+	nodes[0].setLatency(300 * time.Millisecond)
+	nodes[1].setLatency(100 * time.Millisecond)
+	nodes[2].setLatency(200 * time.Millisecond)
+	nodes[0].lastUsed, nodes[1].lastUsed, nodes[2].lastUsed = 3, 2, 1
+
+	rr := RoundRobin()
+	for i := 0; i < 6; i++ {
+		if got := rr.Select(nodes, ""); got != i%3 {
+			t.Fatalf("round robin %d: got %d", i, got)
+		}
+	}
+	if got := LowestLatency().Select(nodes, ""); got != 1 {
+		t.Fatalf("lowest latency: got %d", got)
+	}
+	if got := LeastRecentlyUsed().Select(nodes, ""); got != 2 {
+		t.Fatalf("least recently used: got %d", got)
+	}
+	if got := WeightedRandom().Select(nodes, ""); got < 0 || got >= len(nodes) {
+		t.Fatalf("weighted random: got %d", got)
+	}
+
+	//相同的key选择相同的节点,去掉其他节点不影响
+	ch := ConsistentHash()
+	for _, key := range []string{"k1", "k2", "k3", "k4"} {
+		n := nodes[ch.Select(nodes, key)]
+		for i := range nodes {
+			if nodes[i] == n {
+				continue
+			}
+			rest := append(append([]*Node{}, nodes[:i]...), nodes[i+1:]...)
+			if rest[ch.Select(rest, key)] != n {
+				t.Fatalf("key %s moved after removing %s", key, nodes[i].origin)
+			}
+		}
+	}
+}
+
+func TestGetByKey(t *testing.T) {
+	p, _ := testPool(t, 3, WithSelector(ConsistentHash()))
+	n, err := p.GetByKey(context.Background(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//目标节点借出时等待,不换成其他节点
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := p.GetByKey(ctx, "key"); err != ErrDeadline {
+		t.Fatalf("want ErrDeadline, got: %v", err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		p.Put(n)
+	}()
+	if m, err := p.GetByKey(context.Background(), "key"); err != nil || m != n {
+		t.Fatalf("want the hashed node after put: %v", err)
+	}
+
+	//目标节点借出期间不可用,归还后等待的调用者选择其他节点
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		n.setUnhealthy(true)
+		p.Put(n)
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if m, err := p.GetByKey(ctx, "key"); err != nil || m == n {
+		t.Fatalf("want another node after the hashed node went unhealthy: %v", err)
+	}
+}
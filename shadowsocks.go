@@ -0,0 +1,157 @@
+package xray_pool
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/injoyai/conv"
+)
+
+/*
+ParseShadowsocks 解析 ss:// 链接
+SIP002: ss://YWVzLTI1Ni1nY206cGFzc3dvcmQ@1.2.3.4:8388/?plugin=v2ray-plugin%3Bmode%3Dwebsocket%3Bhost%3Dexample.com%3Btls#tag
+SIP002(2022): ss://2022-blake3-aes-128-gcm:cGFzc3dvcmQ%3D@1.2.3.4:8388#tag
+旧格式: ss://YWVzLTI1Ni1nY206cGFzc3dvcmRAMS4yLjMuNDo4Mzg4#tag
+*/
+func ParseShadowsocks(raw string) (*ShadowsocksConfig, error) {
+	raw = strings.TrimPrefix(raw, "ss://")
+
+	// 分离备注
+	parts := strings.SplitN(raw, "#", 2)
+	link := parts[0]
+	remark := ""
+	if len(parts) > 1 {
+		remark, _ = url.QueryUnescape(parts[1])
+	}
+
+	c := &ShadowsocksConfig{remark: remark}
+
+	if !strings.Contains(link, "@") {
+		// 旧格式,整体base64编码
+		query := ""
+		if i := strings.IndexAny(link, "/?"); i >= 0 {
+			link, query = link[:i], link[i:]
+		}
+		bs, err := decodeBase64(link)
+		if err != nil {
+			return nil, err
+		}
+		link = string(bs) + query
+	}
+
+	i := strings.LastIndex(link, "@")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid shadowsocks link: %s", raw)
+	}
+	userinfo := link[:i]
+	u, err := url.Parse("ss://" + link[i+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	// 用户信息,base64(method:password)或者明文(2022加密方式)
+	if s, err := url.PathUnescape(userinfo); err == nil {
+		userinfo = s
+	}
+	if !strings.Contains(userinfo, ":") {
+		bs, err := decodeBase64(userinfo)
+		if err != nil {
+			return nil, err
+		}
+		userinfo = string(bs)
+	}
+	method, password, ok := strings.Cut(userinfo, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid shadowsocks userinfo: %s", raw)
+	}
+
+	c.method = method
+	c.password = password
+	c.hostname = u.Hostname()
+	c.port = conv.Int(u.Port())
+	if c.hostname == "" || c.port == 0 {
+		return nil, fmt.Errorf("invalid shadowsocks address: %s", raw)
+	}
+
+	// 插件,plugin=name;k=v;flag
+	if plugin := u.Query().Get("plugin"); plugin != "" {
+		ls := strings.Split(plugin, ";")
+		c.plugin = ls[0]
+		c.pluginOpts = make(map[string]string)
+		for _, v := range ls[1:] {
+			k, v, _ := strings.Cut(v, "=")
+			c.pluginOpts[k] = v
+		}
+		if c.streamSettings, err = c.pluginStreamSettings(); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+type ShadowsocksConfig struct {
+	remark         string
+	hostname       string
+	port           int
+	method         string
+	password       string
+	plugin         string            // 插件名称
+	pluginOpts     map[string]string // 插件参数
+	streamSettings *StreamSettings
+}
+
+func (c *ShadowsocksConfig) Remark() string   { return c.remark }
+func (c *ShadowsocksConfig) Protocol() string { return Shadowsocks }
+func (c *ShadowsocksConfig) Hostname() string { return c.hostname }
+func (c *ShadowsocksConfig) Port() int        { return c.port }
+func (c *ShadowsocksConfig) Method() string   { return c.method }
+
+// Plugin 插件名称和参数
+func (c *ShadowsocksConfig) Plugin() (string, map[string]string) {
+	return c.plugin, c.pluginOpts
+}
+
+func (c *ShadowsocksConfig) Settings() *Settings {
+	return &Settings{
+		Servers: []Server{
+			{
+				Address:  c.hostname,
+				Port:     c.port,
+				Method:   c.method,
+				Password: c.password,
+			},
+		},
+	}
+}
+
+func (c *ShadowsocksConfig) StreamSettings() *StreamSettings {
+	return c.streamSettings
+}
+
+// pluginStreamSettings 插件转换成传输配置,xray不支持插件,只支持能转换成websocket的v2ray-plugin
+func (c *ShadowsocksConfig) pluginStreamSettings() (*StreamSettings, error) {
+	switch c.plugin {
+	case "v2ray-plugin", "xray-plugin":
+		if mode := c.pluginOpts["mode"]; mode != "" && mode != "websocket" {
+			return nil, fmt.Errorf("unsupported %s mode: %s", c.plugin, mode)
+		}
+		host := conv.Select(c.pluginOpts["host"] == "", c.hostname, c.pluginOpts["host"])
+		ss := &StreamSettings{
+			Network:  "ws",
+			Security: None,
+			WsSettings: &WsSettings{
+				Path: conv.Select(c.pluginOpts["path"] == "", "/", c.pluginOpts["path"]),
+				Host: host,
+			},
+		}
+		if _, ok := c.pluginOpts["tls"]; ok {
+			ss.Security = "tls"
+			ss.TlsSettings = &TlsSettings{ServerName: host}
+		}
+		return ss, nil
+	default:
+		return nil, fmt.Errorf("unsupported shadowsocks plugin: %s", c.plugin)
+	}
+}
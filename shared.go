@@ -0,0 +1,23 @@
+package xray_pool
+
+import "sync/atomic"
+
+// WithShared 设置共享模式,每个节点最多同时借出perNode次,小于等于0为独占模式(默认)
+// xray进程可以同时处理大量连接,共享模式下少量节点可以服务大量并发调用
+func WithShared(perNode int) Option {
+	return func(p *Pool) {
+		p.shared = perNode
+	}
+}
+
+// WithMaxLeases 设置同时借出的总次数限制,达到限制后Get阻塞,小于等于0不限制
+func WithMaxLeases(max int) Option {
+	return func(p *Pool) {
+		p.maxLeases = max
+	}
+}
+
+// Leased 当前借出的总次数
+func (p *Pool) Leased() int {
+	return int(atomic.LoadInt32(&p.leased))
+}
@@ -0,0 +1,143 @@
+package xray_pool
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/injoyai/logs"
+)
+
+// WithSingleProcess 单进程模式,所有节点运行在同一个进程中
+// 每个节点一个入站端口,通过入站标签路由到对应的出站,节点多时可以大幅减少内存和进程数
+// 节点的代理地址(Node.Proxy)不变,新增或重启节点时会重启进程
+func WithSingleProcess() Option {
+	return func(p *Pool) {
+		p.host = &hostProcess{}
+	}
+}
+
+// hostProcess 单进程模式下运行所有节点的进程
+type hostProcess struct {
+	mu      sync.Mutex
+	nodes   []*Node
+	process *exec.Cmd
+}
+
+// start 把节点加入进程,重新生成配置并重启进程
+// 节点都已经在运行中时不重启
+func (h *hostProcess) start(nodes []*Node, ports []int, protocol, configDir string, cmd []string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	changed := h.process == nil
+	for i, n := range nodes {
+		if slices.Contains(h.nodes, n) && !n.Closed() {
+			continue
+		}
+		changed = true
+		n.host = h
+		n.setListenPort(ports[i])
+		n.listenProtocol = protocol
+		if !slices.Contains(h.nodes, n) {
+			h.nodes = append(h.nodes, n)
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	config := h.config()
+	h.kill()
+
+	file := configDir + "single.json"
+	if err := os.WriteFile(file, config.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	//所有节点的入站端口都握手成功才算启动成功
+	hosted := slices.Clone(h.nodes)
+	c, err := runProcess(cmd, file, defaultCore.Error, func() error {
+		for _, n := range hosted {
+			if err := probeInbound(protocol, n.ListenPort(), DefaultTimeout); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(c *exec.Cmd) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.process == nil || h.process == c {
+			h.setRunning(false)
+			h.process = nil
+		}
+	})
+	if err != nil {
+		return err
+	}
+	h.process = c
+	h.setRunning(true)
+	logs.Infof("单进程模式启动, 节点数量: %d\n", len(h.nodes))
+	return nil
+}
+
+// remove 把节点移出进程,节点的入站在下次重启进程前仍然保留,没有节点时结束进程
+func (h *hostProcess) remove(n *Node) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.nodes = slices.DeleteFunc(h.nodes, func(v *Node) bool { return v == n })
+	atomic.StoreUint32(&n.running, 0)
+	n.setListenPort(-1)
+	n.checkSpend = -1
+	n.host = nil
+	if len(h.nodes) == 0 {
+		return h.kill()
+	}
+	return nil
+}
+
+// config 生成包含所有节点的配置,入站和出站按端口打标签,通过路由规则一一对应
+func (h *hostProcess) config() Config {
+	config := Config{
+		Log:     DefaultLog,
+		Routing: &Routing{},
+	}
+	for _, n := range h.nodes {
+		inTag := fmt.Sprintf("in-%d", n.ListenPort())
+		outTag := fmt.Sprintf("out-%d", n.ListenPort())
+		in := n.inbound()
+		in.Tag = inTag
+		out := n.outbound()
+		out.Tag = outTag
+		config.Inbounds = append(config.Inbounds, in)
+		config.Outbounds = append(config.Outbounds, out)
+		config.Routing.Rules = append(config.Routing.Rules, Rule{
+			Type:        "field",
+			InboundTag:  []string{inTag},
+			OutboundTag: outTag,
+		})
+	}
+	return config
+}
+
+func (h *hostProcess) setRunning(b bool) {
+	for _, n := range h.nodes {
+		if b {
+			atomic.StoreUint32(&n.running, 1)
+		} else {
+			atomic.StoreUint32(&n.running, 0)
+		}
+	}
+}
+
+func (h *hostProcess) kill() error {
+	if h.process == nil || h.process.Process == nil {
+		return nil
+	}
+	err := h.process.Process.Kill()
+	h.process = nil
+	return err
+}
@@ -0,0 +1,45 @@
+package xray_pool
+
+import (
+	"bytes"
+	"strings"
+)
+
+// decodeSubscription 解析订阅内容,返回节点链接
+// 兼容整体base64编码(标准,URL安全,无填充),\r\n换行,空行和注释行,Clash/Clash.Meta配置,sing-box和SIP008的json
+func decodeSubscription(bs []byte) ([]string, error) {
+	bs = bytes.TrimPrefix(bs, []byte("\xef\xbb\xbf"))
+	bs = bytes.TrimSpace(bs)
+
+	//整体base64编码
+	if !bytes.Contains(bs, []byte("://")) {
+		s := strings.Join(strings.Fields(string(bs)), "")
+		if decoded, err := decodeBase64(s); err == nil {
+			bs = bytes.TrimSpace(decoded)
+		}
+	}
+
+	if isClash(bs) {
+		return decodeClash(bs)
+	}
+
+	if bytes.HasPrefix(bs, []byte("{")) {
+		return decodeJSON(bs)
+	}
+
+	s := strings.ReplaceAll(string(bs), "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	var ls []string
+	for _, l := range strings.Split(s, "\n") {
+		l = strings.TrimSpace(l)
+		switch {
+		case l == "",
+			strings.HasPrefix(l, "#"),
+			strings.HasPrefix(l, "//"),
+			!strings.Contains(l, "://"):
+			continue
+		}
+		ls = append(ls, l)
+	}
+	return ls, nil
+}
@@ -0,0 +1,242 @@
+package xray_pool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/injoyai/conv"
+	"github.com/injoyai/logs"
+)
+
+// decodeJSON 解析json格式的订阅,支持sing-box的outbounds和SIP008的servers,转换成节点链接
+func decodeJSON(bs []byte) ([]string, error) {
+	var cfg struct {
+		Outbounds []singBoxOutbound `json:"outbounds"`
+		Servers   []sip008Server    `json:"servers"`
+	}
+	if err := json.Unmarshal(bs, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Outbounds) == 0 && len(cfg.Servers) == 0 {
+		return nil, errors.New("unsupported json subscription, must be sing-box or SIP008")
+	}
+	ls := make([]string, 0, len(cfg.Outbounds)+len(cfg.Servers))
+	for _, o := range cfg.Outbounds {
+		switch o.Type {
+		case "direct", "block", "dns", "selector", "urltest":
+			continue
+		}
+		l, err := o.link()
+		if err != nil {
+			logs.Warn(err)
+			continue
+		}
+		ls = append(ls, l)
+	}
+	for _, s := range cfg.Servers {
+		ls = append(ls, s.link())
+	}
+	return ls, nil
+}
+
+// sip008Server SIP008格式的Shadowsocks服务器
+type sip008Server struct {
+	ID         string `json:"id"`
+	Remarks    string `json:"remarks"`
+	Server     string `json:"server"`
+	ServerPort int    `json:"server_port"`
+	Password   string `json:"password"`
+	Method     string `json:"method"`
+	Plugin     string `json:"plugin"`
+	PluginOpts string `json:"plugin_opts"`
+}
+
+func (s sip008Server) link() string {
+	plugin := s.Plugin
+	if plugin != "" && s.PluginOpts != "" {
+		plugin += ";" + s.PluginOpts
+	}
+	return ssLink(s.Remarks, s.Server, s.ServerPort, s.Method, s.Password, plugin)
+}
+
+// singBoxOutbound sing-box的出站配置
+type singBoxOutbound struct {
+	Type       string `json:"type"`
+	Tag        string `json:"tag"`
+	Server     string `json:"server"`
+	ServerPort int    `json:"server_port"`
+	UUID       string `json:"uuid"`
+	AlterID    int    `json:"alter_id"`
+	Security   string `json:"security"`
+	Flow       string `json:"flow"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	Method     string `json:"method"`
+	Plugin     string `json:"plugin"`
+	PluginOpts string `json:"plugin_opts"`
+	TLS        *struct {
+		Enabled    bool     `json:"enabled"`
+		ServerName string   `json:"server_name"`
+		Insecure   bool     `json:"insecure"`
+		Alpn       []string `json:"alpn"`
+		UTLS       *struct {
+			Enabled     bool   `json:"enabled"`
+			Fingerprint string `json:"fingerprint"`
+		} `json:"utls"`
+		Reality *struct {
+			Enabled   bool   `json:"enabled"`
+			PublicKey string `json:"public_key"`
+			ShortID   string `json:"short_id"`
+		} `json:"reality"`
+	} `json:"tls"`
+	Transport *struct {
+		Type        string         `json:"type"`
+		Path        string         `json:"path"`
+		Host        any            `json:"host"`
+		Headers     map[string]any `json:"headers"`
+		ServiceName string         `json:"service_name"`
+	} `json:"transport"`
+
+	// wireguard
+	PrivateKey    string   `json:"private_key"`
+	PeerPublicKey string   `json:"peer_public_key"`
+	PreSharedKey  string   `json:"pre_shared_key"`
+	LocalAddress  []string `json:"local_address"`
+	Reserved      []int    `json:"reserved"`
+	MTU           int      `json:"mtu"`
+
+	// hysteria2, tuic
+	Obfs *struct {
+		Type     string `json:"type"`
+		Password string `json:"password"`
+	} `json:"obfs"`
+	CongestionControl string `json:"congestion_control"`
+	UDPRelayMode      string `json:"udp_relay_mode"`
+}
+
+// link 转换成节点链接
+func (o singBoxOutbound) link() (string, error) {
+	addr := net.JoinHostPort(o.Server, conv.String(o.ServerPort))
+	switch o.Type {
+	case Vmess:
+		return vmessLink(o.Tag, o.Server, o.ServerPort, o.UUID, o.AlterID, o.Security, o.transport())
+	case Vless:
+		return queryLink(Vless, o.Tag, o.Server, o.ServerPort, o.UUID, map[string]string{"flow": o.Flow}, o.transport()), nil
+	case Trojan:
+		return queryLink(Trojan, o.Tag, o.Server, o.ServerPort, o.Password, nil, o.transport()), nil
+	case Shadowsocks:
+		plugin := o.Plugin
+		if plugin != "" && o.PluginOpts != "" {
+			plugin += ";" + o.PluginOpts
+		}
+		return ssLink(o.Tag, o.Server, o.ServerPort, o.Method, o.Password, plugin), nil
+	case Socks, Http:
+		u := url.URL{Scheme: conv.Select(o.Type == Socks, "socks5", "http"), Host: addr}
+		if o.TLS != nil && o.TLS.Enabled && o.Type == Http {
+			u.Scheme = "https"
+		}
+		if o.Username != "" {
+			u.User = url.UserPassword(o.Username, o.Password)
+		}
+		return u.String() + "#" + url.QueryEscape(o.Tag), nil
+	case Wireguard:
+		q := url.Values{}
+		q.Set("publickey", o.PeerPublicKey)
+		q.Set("address", strings.Join(o.LocalAddress, ","))
+		if o.PreSharedKey != "" {
+			q.Set("presharedkey", o.PreSharedKey)
+		}
+		if o.MTU > 0 {
+			q.Set("mtu", conv.String(o.MTU))
+		}
+		if len(o.Reserved) > 0 {
+			ls := make([]string, len(o.Reserved))
+			for i, v := range o.Reserved {
+				ls[i] = conv.String(v)
+			}
+			q.Set("reserved", strings.Join(ls, ","))
+		}
+		u := url.URL{Scheme: "wireguard", User: url.User(o.PrivateKey), Host: addr, RawQuery: q.Encode()}
+		return u.String() + "#" + url.QueryEscape(o.Tag), nil
+	case Hysteria2:
+		c := &Hysteria2Config{remark: o.Tag, hostname: o.Server, port: o.ServerPort, password: o.Password}
+		if o.Obfs != nil {
+			c.obfs, c.obfsPassword = o.Obfs.Type, o.Obfs.Password
+		}
+		if o.TLS != nil {
+			c.sni, c.insecure, c.alpn = o.TLS.ServerName, o.TLS.Insecure, o.TLS.Alpn
+		}
+		return c.Link()
+	case Tuic:
+		c := &TuicConfig{
+			remark:            o.Tag,
+			hostname:          o.Server,
+			port:              o.ServerPort,
+			uuid:              o.UUID,
+			password:          o.Password,
+			congestionControl: o.CongestionControl,
+			udpRelayMode:      o.UDPRelayMode,
+		}
+		if o.TLS != nil {
+			c.sni, c.insecure, c.alpn = o.TLS.ServerName, o.TLS.Insecure, o.TLS.Alpn
+		}
+		return c.Link()
+	default:
+		return "", fmt.Errorf("unsupported sing-box outbound type: %s(%s)", o.Type, o.Tag)
+	}
+}
+
+// transport 传输参数
+func (o singBoxOutbound) transport() transport {
+	t := transport{Network: "tcp", Security: None}
+	if o.TLS != nil && o.TLS.Enabled {
+		t.Security = "tls"
+		t.SNI = o.TLS.ServerName
+		t.AllowInsecure = o.TLS.Insecure
+		t.Alpn = strings.Join(o.TLS.Alpn, ",")
+		if o.TLS.UTLS != nil && o.TLS.UTLS.Enabled {
+			t.Fingerprint = o.TLS.UTLS.Fingerprint
+		}
+		if o.TLS.Reality != nil && o.TLS.Reality.Enabled {
+			t.Security = "reality"
+			t.PublicKey = o.TLS.Reality.PublicKey
+			t.ShortID = o.TLS.Reality.ShortID
+		}
+	}
+	if o.Transport != nil {
+		t.Network = o.Transport.Type
+		t.Path = o.Transport.Path
+		t.Host = joinAny(o.Transport.Host)
+		for k, v := range o.Transport.Headers {
+			if strings.EqualFold(k, "Host") && t.Host == "" {
+				t.Host = joinAny(v)
+			}
+		}
+		switch t.Network {
+		case "grpc":
+			t.Path = o.Transport.ServiceName
+		case "http":
+			t.Network = "h2"
+		}
+	}
+	return t
+}
+
+// joinAny 字符串或者字符串数组,数组用逗号连接
+func joinAny(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case []any:
+		ls := make([]string, 0, len(val))
+		for _, s := range val {
+			ls = append(ls, conv.String(s))
+		}
+		return strings.Join(ls, ",")
+	}
+	return ""
+}
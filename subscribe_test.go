@@ -0,0 +1,218 @@
+package xray_pool
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDecodeSubscription(t *testing.T) {
+	lines := "vless://b831381d-6324-4d53-ad4f-8cda48b30811@v.example.com:443?security=tls&type=ws&host=cdn.example.com&path=%2Fws#vless\n" +
+		"trojan://password@t.example.com:443?sni=t.example.com#trojan"
+	want := []string{
+		"vless v.example.com:443 vless ws/tls /ws cdn.example.com",
+		"trojan t.example.com:443 trojan tcp/tls",
+	}
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"base64", base64.StdEncoding.EncodeToString([]byte(lines)), want},
+		{"base64-wrapped", wrap(base64.StdEncoding.EncodeToString([]byte(lines)), 76), want},
+		{"base64-url-unpadded", base64.RawURLEncoding.EncodeToString([]byte(lines)), want},
+		{"plain-crlf-comment", "\xef\xbb\xbf# 注释\r\n// 注释\r\n\r\n" + strings.ReplaceAll(lines, "\n", "\r\n") + "\r\nnot a link\r\n", want},
+		{"clash", clashYaml, []string{
+			"vmess ws.example.com:443 vmess-ws ws/tls /ray cdn.example.com",
+			"vless grpc.example.com:443 vless-grpc grpc/tls svc",
+			"vless 1.2.3.4:443 vless-reality tcp/reality pubkey 0123",
+			"shadowsocks ss.example.com:8388 ss",
+			"hysteria2 hy.example.com:8443 hy2 pass hy.example.com salamander true",
+			"tuic tuic.example.com:443 tuic b831381d-6324-4d53-ad4f-8cda48b30811 pass tuic.example.com bbr",
+		}},
+		{"sing-box", singBoxJSON, []string{
+			"vless v.example.com:443 vless-ws ws/tls /ws cdn.example.com",
+			"trojan t.example.com:443 trojan-grpc grpc/tls svc",
+			"vless 1.2.3.4:443 vless-reality tcp/reality pubkey 0123",
+			"shadowsocks ss.example.com:8388 ss",
+			"hysteria2 hy.example.com:8443 hy2 pass hy.example.com salamander true",
+			"tuic tuic.example.com:443 tuic b831381d-6324-4d53-ad4f-8cda48b30811 pass tuic.example.com bbr",
+		}},
+		{"sip008", sip008JSON, []string{
+			"shadowsocks ss1.example.com:8388 ss1",
+			"shadowsocks ss2.example.com:443 ss2 ws/tls / cdn.example.com",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ls, err := decodeSubscription([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ls) != len(tt.want) {
+				t.Fatalf("want %d nodes, got %d: %v", len(tt.want), len(ls), ls)
+			}
+			for i, l := range ls {
+				if got := summary(t, l); got != tt.want[i] {
+					t.Fatalf("node %d:\nwant: %s\ngot:  %s", i, tt.want[i], got)
+				}
+			}
+		})
+	}
+}
+
+const clashYaml = `port: 7890
+mode: rule
+proxies:
+  - name: vmess-ws
+    type: vmess
+    server: ws.example.com
+    port: 443
+    uuid: b831381d-6324-4d53-ad4f-8cda48b30811
+    alterId: 0
+    cipher: auto
+    tls: true
+    network: ws
+    ws-opts:
+      path: /ray
+      headers:
+        Host: cdn.example.com
+  - name: vless-grpc
+    type: vless
+    server: grpc.example.com
+    port: 443
+    uuid: b831381d-6324-4d53-ad4f-8cda48b30811
+    tls: true
+    network: grpc
+    grpc-opts:
+      grpc-service-name: svc
+  - name: vless-reality
+    type: vless
+    server: 1.2.3.4
+    port: 443
+    uuid: b831381d-6324-4d53-ad4f-8cda48b30811
+    flow: xtls-rprx-vision
+    servername: www.example.com
+    client-fingerprint: chrome
+    reality-opts:
+      public-key: pubkey
+      short-id: "0123"
+  - name: ss
+    type: ss
+    server: ss.example.com
+    port: 8388
+    cipher: aes-256-gcm
+    password: secret
+  - name: hy2
+    type: hysteria2
+    server: hy.example.com
+    port: 8443
+    password: pass
+    sni: hy.example.com
+    skip-cert-verify: true
+    obfs: salamander
+    obfs-password: obfs
+  - name: tuic
+    type: tuic
+    server: tuic.example.com
+    port: 443
+    uuid: b831381d-6324-4d53-ad4f-8cda48b30811
+    password: pass
+    sni: tuic.example.com
+    alpn: [h3]
+    congestion-controller: bbr
+    udp-relay-mode: native
+  - name: unsupported
+    type: snell
+    server: snell.example.com
+    port: 443
+rules:
+  - MATCH,DIRECT
+`
+
+const singBoxJSON = `{
+  "log": {"level": "info"},
+  "outbounds": [
+    {"type": "selector", "tag": "proxy", "outbounds": ["vless-ws"]},
+    {"type": "vless", "tag": "vless-ws", "server": "v.example.com", "server_port": 443,
+     "uuid": "b831381d-6324-4d53-ad4f-8cda48b30811",
+     "tls": {"enabled": true, "server_name": "cdn.example.com"},
+     "transport": {"type": "ws", "path": "/ws", "headers": {"Host": "cdn.example.com"}}},
+    {"type": "trojan", "tag": "trojan-grpc", "server": "t.example.com", "server_port": 443,
+     "password": "password", "tls": {"enabled": true},
+     "transport": {"type": "grpc", "service_name": "svc"}},
+    {"type": "vless", "tag": "vless-reality", "server": "1.2.3.4", "server_port": 443,
+     "uuid": "b831381d-6324-4d53-ad4f-8cda48b30811", "flow": "xtls-rprx-vision",
+     "tls": {"enabled": true, "server_name": "www.example.com",
+             "utls": {"enabled": true, "fingerprint": "chrome"},
+             "reality": {"enabled": true, "public_key": "pubkey", "short_id": "0123"}}},
+    {"type": "shadowsocks", "tag": "ss", "server": "ss.example.com", "server_port": 8388,
+     "method": "aes-256-gcm", "password": "secret"},
+    {"type": "hysteria2", "tag": "hy2", "server": "hy.example.com", "server_port": 8443,
+     "password": "pass", "obfs": {"type": "salamander", "password": "obfs"},
+     "tls": {"enabled": true, "server_name": "hy.example.com", "insecure": true}},
+    {"type": "tuic", "tag": "tuic", "server": "tuic.example.com", "server_port": 443,
+     "uuid": "b831381d-6324-4d53-ad4f-8cda48b30811", "password": "pass",
+     "congestion_control": "bbr", "udp_relay_mode": "native",
+     "tls": {"enabled": true, "server_name": "tuic.example.com", "alpn": ["h3"]}},
+    {"type": "direct", "tag": "direct"}
+  ]
+}`
+
+const sip008JSON = `{
+  "version": 1,
+  "servers": [
+    {"id": "1", "remarks": "ss1", "server": "ss1.example.com", "server_port": 8388,
+     "password": "secret", "method": "aes-256-gcm"},
+    {"id": "2", "remarks": "ss2", "server": "ss2.example.com", "server_port": 443,
+     "password": "secret", "method": "chacha20-ietf-poly1305",
+     "plugin": "v2ray-plugin", "plugin_opts": "tls;host=cdn.example.com"}
+  ]
+}`
+
+// summary 解析节点链接,返回协议,地址,备注和传输参数的摘要
+func summary(t *testing.T, link string) string {
+	n := &Node{origin: link}
+	if err := n.parse(); err != nil {
+		t.Fatalf("parse %s: %v", link, err)
+	}
+	return describe(n)
+}
+
+// describe 节点的协议,地址,备注和传输参数的摘要
+func describe(n *Node) string {
+	s := fmt.Sprintf("%s %s:%d %s", n.Protocol(), n.Hostname(), n.Port(), n.Remark())
+	switch c := n.Vnexter.(type) {
+	case *Hysteria2Config:
+		s += fmt.Sprintf(" %s %s %s %v", c.password, c.sni, c.obfs, c.insecure)
+	case *TuicConfig:
+		s += fmt.Sprintf(" %s %s %s %s", c.uuid, c.password, c.sni, c.congestionControl)
+	}
+	ss := n.StreamSettings()
+	if ss == nil {
+		return s
+	}
+	s += " " + ss.Network + "/" + ss.Security
+	switch {
+	case ss.WsSettings != nil:
+		s += " " + ss.WsSettings.Path + " " + ss.WsSettings.Host
+	case ss.GrpcSettings != nil:
+		s += " " + ss.GrpcSettings.ServiceName
+	}
+	if ss.RealitySettings != nil {
+		s += " " + ss.RealitySettings.PublicKey + " " + ss.RealitySettings.ShortID
+	}
+	return s
+}
+
+// wrap 按n个字符换行,模拟分行的base64
+func wrap(s string, n int) string {
+	var b strings.Builder
+	for len(s) > n {
+		b.WriteString(s[:n] + "\r\n")
+		s = s[n:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
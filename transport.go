@@ -0,0 +1,83 @@
+package xray_pool
+
+import (
+	"strings"
+
+	"github.com/injoyai/conv"
+)
+
+// transport 链接中通用的传输和安全参数,用于生成StreamSettings
+type transport struct {
+	Network       string // 传输方式 tcp ws grpc h2 httpupgrade xhttp
+	Security      string // 安全类型 none tls reality
+	HeaderType    string // 伪装类型,tcp的http伪装,grpc的multi模式
+	Host          string // 伪装域名
+	Path          string // 路径,grpc时为serviceName
+	Mode          string // xhttp的模式,grpc的multi模式
+	SNI           string
+	Alpn          string // 多个用逗号分隔
+	Fingerprint   string
+	AllowInsecure bool
+
+	// reality
+	PublicKey     string
+	ShortID       string
+	SpiderX       string
+	Mldsa64Verify string
+}
+
+func (t transport) streamSettings() *StreamSettings {
+	ss := &StreamSettings{
+		Network:  conv.Select(t.Network == "", "tcp", t.Network),
+		Security: conv.Select(t.Security == "", None, t.Security),
+	}
+
+	switch ss.Network {
+	case "tcp":
+		if t.HeaderType == "http" {
+			req := &HeaderRequest{Path: []string{conv.Select(t.Path == "", "/", t.Path)}}
+			if t.Host != "" {
+				req.Headers = map[string][]string{"Host": strings.Split(t.Host, ",")}
+			}
+			ss.TcpSettings = &TcpSettings{Header: &Header{Type: "http", Request: req}}
+		}
+	case "ws":
+		ss.WsSettings = &WsSettings{Path: t.Path, Host: t.Host}
+	case "grpc":
+		ss.GrpcSettings = &GrpcSettings{ServiceName: t.Path, MultiMode: t.HeaderType == "multi" || t.Mode == "multi"}
+	case "h2", "http":
+		ss.Network = "h2"
+		ss.HttpSettings = &HttpSettings{Path: t.Path}
+		if t.Host != "" {
+			ss.HttpSettings.Host = strings.Split(t.Host, ",")
+		}
+	case "httpupgrade":
+		ss.HttpupgradeSettings = &HttpupgradeSettings{Path: t.Path, Host: t.Host}
+	case "xhttp", "splithttp":
+		ss.Network = "xhttp"
+		ss.XhttpSettings = &XhttpSettings{Path: t.Path, Host: t.Host, Mode: t.Mode}
+	}
+
+	switch ss.Security {
+	case "tls":
+		ss.TlsSettings = &TlsSettings{
+			ServerName:    conv.Select(t.SNI == "", t.Host, t.SNI),
+			AllowInsecure: t.AllowInsecure,
+			Fingerprint:   t.Fingerprint,
+		}
+		if t.Alpn != "" {
+			ss.TlsSettings.Alpn = strings.Split(t.Alpn, ",")
+		}
+	case "reality":
+		ss.RealitySettings = &RealitySettings{
+			ServerName:    t.SNI,
+			Fingerprint:   conv.Select(t.Fingerprint == "", "chrome", t.Fingerprint),
+			PublicKey:     t.PublicKey,
+			ShortID:       t.ShortID,
+			SpiderX:       t.SpiderX,
+			Mldsa64Verify: t.Mldsa64Verify,
+		}
+	}
+
+	return ss
+}